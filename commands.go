@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/CatWantsMeow/gtetris/config"
+	"github.com/CatWantsMeow/gtetris/game"
+	"github.com/CatWantsMeow/gtetris/leaderboard"
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/netplay"
+	"github.com/CatWantsMeow/gtetris/profile"
+	"github.com/CatWantsMeow/gtetris/replay"
+	"github.com/CatWantsMeow/gtetris/telnet"
+)
+
+// Exit codes of the process.
+const (
+	ExitOK = iota
+	ExitError
+	ExitUsage
+	ExitMismatch
+)
+
+// MismatchError is returned by the replay command when a replay doesn't
+// play as recorded.
+var MismatchError = errors.New("replay doesn't match its recorded stats")
+
+// session is what commands run with: the config, the profile played as,
+// options of games built from them and arguments of the command.
+type session struct {
+	cmd  *command
+	cfg  *config.Config
+	prof *profile.Profile
+	opts game.Options
+	args []string
+
+	// Flags of commands which aren't options of the config.
+	resume bool
+	seek   int
+	verify bool
+}
+
+// arg returns the argument i of the command or an empty string.
+func (s *session) arg(i int) string {
+	if i < len(s.args) {
+		return s.args[i]
+	}
+	return ""
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	help    string
+
+	// Arguments the command takes at least and at most.
+	minArgs, maxArgs int
+
+	// Options of the config also accepted as flags after the command.
+	options []string
+
+	// flags registers flags of the command which aren't options.
+	flags func(fs *flag.FlagSet, s *session)
+
+	run func(s *session) error
+
+	// Commands which don't play games or edit the profile may run with
+	// conflicting key bindings.
+	anyKeys bool
+}
+
+var gameOptions = []string{"mode", "seed", "width", "height", "start-level", "entry-delay", "clear-delay"}
+
+var commands = []*command{
+	{
+		name:    "play",
+		summary: "play a game right away",
+		help: "Plays a game of the given mode without going through the title menu,\n" +
+			"or resumes the game saved with ctrl-s or on exit.",
+		options: append([]string{"spectate"}, gameOptions...),
+		flags: func(fs *flag.FlagSet, s *session) {
+			fs.BoolVar(&s.resume, "resume", false, "Resume the game saved with ctrl-s or on exit.")
+		},
+		run: play,
+	},
+	{
+		name:    "bot",
+		summary: "watch the bot play a game",
+		help: "Plays a game of the given mode with the bot in place of the first\n" +
+			"player. The game isn't recorded and keeps no scores.",
+		options: gameOptions,
+		run:     bot,
+	},
+	{
+		name:    "replay",
+		args:    "file",
+		summary: "play and verify a recorded game",
+		help: fmt.Sprintf(
+			"Plays a replay and checks it ends with the recorded stats. Exits with\n"+
+				"code %d if it doesn't.", ExitMismatch,
+		),
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet, s *session) {
+			fs.IntVar(&s.seek, "seek", 0, "Piece number to start playback at.")
+			fs.BoolVar(&s.verify, "verify", false, "Only verify the replay without playing it.")
+		},
+		run:     playReplay,
+		anyKeys: true,
+	},
+	{
+		name:    "scores",
+		args:    "[mode]",
+		summary: "print high scores",
+		help:    "Prints high scores of the profile in mode or in every mode.",
+		maxArgs: 1,
+		run:     func(s *session) error { return printScores(s.prof, s.arg(0)) },
+		anyKeys: true,
+	},
+	{
+		name:    "stats",
+		args:    "[mode]",
+		summary: "print lifetime statistics",
+		help:    "Prints statistics of finished games of the profile in mode or in\nevery mode.",
+		maxArgs: 1,
+		run:     func(s *session) error { return printStats(s.prof, s.arg(0)) },
+		anyKeys: true,
+	},
+	{
+		name:    "serve",
+		args:    "[addr]",
+		summary: "wait for an opponent",
+		help:    fmt.Sprintf("Waits for an opponent to join a versus game on addr, %s by default.", netplay.DefaultAddr),
+		maxArgs: 1,
+		run:     online,
+	},
+	{
+		name:    "join",
+		args:    "host:port",
+		summary: "play against a waiting opponent",
+		help:    "Joins a versus game of an opponent waiting with serve.",
+		minArgs: 1, maxArgs: 1,
+		run: online,
+	},
+	{
+		name:    "watch",
+		args:    "host:port",
+		summary: "watch a game started with -spectate",
+		help:    "Shows a game streamed with play -spectate read-only.",
+		minArgs: 1, maxArgs: 1,
+		run:     online,
+		anyKeys: true,
+	},
+	{
+		name:    "battle",
+		args:    "host:port",
+		summary: "join a battle royale lobby",
+		help:    "Joins rounds of a battle royale lobby hosted with lobby.",
+		minArgs: 1, maxArgs: 1,
+		run: battle,
+	},
+	{
+		name:    "lobby",
+		args:    "[addr]",
+		summary: "host battle royale rounds",
+		help:    fmt.Sprintf("Hosts battle royale rounds on addr, %s by default.", netplay.DefaultLobbyAddr),
+		maxArgs: 1,
+		options: []string{"bots", "min-players"},
+		run:     hostLobby,
+	},
+	{
+		name:    "telnet",
+		args:    "[addr]",
+		summary: "host games for telnet clients",
+		help:    fmt.Sprintf("Hosts games for telnet clients on addr, %s by default.", telnet.DefaultAddr),
+		maxArgs: 1,
+		run:     hostTelnet,
+	},
+	{
+		name:    "leaderboard",
+		args:    "[addr]",
+		summary: "host a leaderboard",
+		help:    fmt.Sprintf("Hosts a leaderboard on addr, %s by default.", leaderboard.DefaultAddr),
+		maxArgs: 1,
+		options: []string{"data"},
+		run:     hostLeaderboard,
+		anyKeys: true,
+	},
+	{
+		name:    "profiles",
+		summary: "list profiles",
+		help:    "Prints names of profiles.",
+		run: func(s *session) error {
+			for _, name := range profile.List() {
+				fmt.Println(name)
+			}
+			return nil
+		},
+		anyKeys: true,
+	},
+	{
+		name:    "keys",
+		summary: "edit key bindings of the profile",
+		help:    "Shows the key settings for the profile.",
+		run:     func(s *session) error { return editKeys(s.prof, s.opts.Keys, s.cfg.Mouse) },
+		anyKeys: true,
+	},
+	{
+		name:    "config",
+		summary: "print the effective configuration",
+		help:    "Prints every option with its value and where the value comes from.",
+		run:     func(s *session) error { return s.cfg.Write(os.Stdout) },
+		anyKeys: true,
+	},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet returns the flag set of the command for s.
+func (cmd *command) flagSet(s *session) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	for _, name := range cmd.options {
+		s.cfg.Flag(fs, name)
+	}
+	if cmd.flags != nil {
+		cmd.flags(fs, s)
+	}
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage:\n  %s [flags] %s", os.Args[0], cmd.name)
+		if cmd.options != nil || cmd.flags != nil {
+			fmt.Fprint(out, " [command flags]")
+		}
+		if cmd.args != "" {
+			fmt.Fprintf(out, " %s", cmd.args)
+		}
+		fmt.Fprintf(out, "\n\n%s\n", cmd.help)
+		if cmd.options != nil || cmd.flags != nil {
+			fmt.Fprint(out, "\nCommand flags:\n")
+			fs.PrintDefaults()
+		}
+		fmt.Fprintf(out, "\nSee %s -h for flags of every command.\n", os.Args[0])
+	}
+	return fs
+}
+
+// parse parses flags and arguments of the command given in args.
+func (cmd *command) parse(s *session, args []string) error {
+	fs := cmd.flagSet(s)
+	err := s.cfg.ParseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	s.args = fs.Args()
+	if len(s.args) < cmd.minArgs || len(s.args) > cmd.maxArgs {
+		err := fmt.Errorf("%s takes only %s", cmd.name, cmd.args)
+		switch {
+		case cmd.maxArgs == 0:
+			err = fmt.Errorf("%s takes no arguments", cmd.name)
+		case cmd.minArgs == cmd.maxArgs:
+			err = fmt.Errorf("%s requires %s", cmd.name, cmd.args)
+		}
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return err
+	}
+	return nil
+}
+
+func play(s *session) error {
+	s.opts.Resume = s.resume
+	if s.cfg.Spectate != "" {
+		spectators, err := netplay.NewBroadcaster(s.cfg.Spectate)
+		if err != nil {
+			return err
+		}
+		defer spectators.Close()
+		s.opts.Spectators = spectators
+	}
+	return game.Run(s.opts)
+}
+
+func bot(s *session) error {
+	s.opts.Bot = true
+	return game.Run(s.opts)
+}
+
+func playReplay(s *session) error {
+	rep, err := replay.Load(s.arg(0))
+	if err != nil {
+		return err
+	}
+
+	var v *game.Verification
+	if s.verify {
+		v, err = game.Verify(rep)
+	} else {
+		v, err = game.Play(rep, s.seek, s.opts)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(v)
+	if !v.OK() {
+		return MismatchError
+	}
+	return nil
+}
+
+// online plays or watches a versus game over the network.
+func online(s *session) error {
+	conn, err := connect(s.cmd.name, s.arg(0))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if s.cmd.name == "watch" {
+		return game.Watch(conn, s.cfg.Debug)
+	}
+	s.opts.Conn = conn
+	return game.Run(s.opts)
+}
+
+func battle(s *session) error {
+	fmt.Printf("Connecting to %s...\n", s.arg(0))
+	conn, err := netplay.Dial(s.arg(0))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s.opts.Lobby = conn
+	return game.Run(s.opts)
+}
+
+func hostLobby(s *session) error {
+	log.SetOutput(os.Stdout)
+	return lobby(s.arg(0), s.cfg.Bots, s.cfg.MinPlayers, s.opts)
+}
+
+func hostTelnet(s *session) error {
+	addr := s.arg(0)
+	if addr == "" {
+		addr = telnet.DefaultAddr
+	}
+	log.SetOutput(os.Stdout)
+	fmt.Printf("Hosting games for telnet clients on %s...\n", addr)
+	return telnet.ListenAndServe(addr, s.opts)
+}
+
+func hostLeaderboard(s *session) error {
+	addr := s.arg(0)
+	if addr == "" {
+		addr = leaderboard.DefaultAddr
+	}
+	log.SetOutput(os.Stdout)
+	fmt.Printf("Hosting leaderboard on %s, storing scores in %s...\n", addr, s.cfg.Data)
+	return leaderboard.ListenAndServe(addr, s.cfg.Data, game.VerifyScore)
+}
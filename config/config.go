@@ -0,0 +1,304 @@
+// Package config gathers options of the game from a config file in the
+// XDG config directory, GTETRIS_* environment variables and flags.
+//
+// The config file has one "name = value" line per option named like
+// its flag, with blank lines and lines starting with # ignored. Each
+// source overrides the ones before it: defaults, settings of the
+// profile, the config file, the environment and flags. The settings
+// screen saves to the profile, so the config file, shared by every
+// profile, overrides them.
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CatWantsMeow/gtetris/netplay"
+	"github.com/CatWantsMeow/gtetris/xdg"
+)
+
+const (
+	FileName  = "config"
+	EnvPrefix = "GTETRIS_"
+
+	// PathEnv names another config file to read instead of the one in
+	// the config directory.
+	PathEnv = EnvPrefix + "CONFIG"
+
+	// NoReplays as the replays directory turns saving replays off.
+	NoReplays = "none"
+)
+
+// Source is where a value of an option comes from. Later sources take
+// precedence over earlier ones.
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceProfile
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+var sourceNames = []string{"default", "profile", "file", "env", "flag"}
+
+func (s Source) String() string {
+	return sourceNames[s]
+}
+
+// Config holds options of the game.
+type Config struct {
+	Debug       bool
+	Fast        bool
+	NoAnim      bool
+	Mode        string
+	Spectate    string
+	Name        string
+	Bots        int
+	Seed        int64
+	Leaderboard string
+	Replays     string
+	Data        string
+	MinPlayers  int
+	Profile     string
+	Mouse       bool
+	Ghost       bool
+	Previews    int
+	DAS         int
+	ARR         int
+	Theme       string
+	Colors      string
+	ColorMode   string
+	Glyphs      bool
+	Layout      string
+	Sound       bool
+	Width       int
+	Height      int
+	StartLevel  int
+	EntryDelay  int
+	ClearDelay  int
+
+	flags   *flag.FlagSet
+	names   []string
+	sources map[string]Source
+	origins map[string]string
+}
+
+// New returns a config with default values and registers its options as
+// flags of fs.
+func New(fs *flag.FlagSet) *Config {
+	c := &Config{
+		flags:   fs,
+		sources: make(map[string]Source),
+		origins: make(map[string]string),
+	}
+
+	c.boolVar(&c.Debug, "debug", false, "Run game in debug mode.")
+	c.boolVar(&c.Fast, "fast", false, "Speeds up game.")
+	c.boolVar(&c.NoAnim, "noanim", false, "Disables line clear and lock animations.")
+	c.stringVar(&c.Mode, "mode", "classic", "Game mode: classic, master (20G), big, coop or versus.")
+	c.stringVar(&c.Spectate, "spectate", "", "Address to stream the game to spectators on.")
+	c.stringVar(&c.Name, "name", "", "Name shown to other players and in high scores, the profile's one by default.")
+	c.intVar(&c.Bots, "bots", 0, "Number of simulated players joined to a hosted lobby.")
+	c.int64Var(&c.Seed, "seed", 0, "Seed of random blocks, a new one for every game if zero.")
+	c.stringVar(&c.Leaderboard, "leaderboard", "", "URL of a leaderboard to submit scores to, e.g. http://localhost:7780.")
+	c.stringVar(&c.Replays, "replays", "", "Directory to save replays of finished games to instead of the profile's one, or none to save no replays.")
+	c.stringVar(&c.Data, "data", "leaderboard", "Directory a hosted leaderboard stores scores in.")
+	c.intVar(&c.MinPlayers, "min-players", netplay.MinPlayers, "Number of players needed to start a battle royale round.")
+	c.stringVar(&c.Profile, "profile", "", "Profile to play as, chosen from a menu if there are several.")
+	c.boolVar(&c.Mouse, "mouse", false, "Control the game and menus with the mouse too.")
+	c.boolVar(&c.Ghost, "ghost", true, "Show where falling blocks would land.")
+	c.intVar(&c.Previews, "previews", 1, "Number of next blocks shown.")
+	c.intVar(&c.DAS, "das", 0, "Frames a held left or right key waits before repeating.")
+	c.intVar(&c.ARR, "arr", 0, "Frames between repeated moves of a held left or right key.")
+	c.stringVar(&c.Theme, "theme", "classic", "Color theme of blocks: classic, guideline, monochrome, high-contrast, pastel, or okabe-ito and tol for color blindness.")
+	c.stringVar(&c.Colors, "colors", "", "Colors of blocks replacing the theme's ones, e.g. I=#00f0f0,L=#f0a000,garbage=gray.")
+	c.stringVar(&c.ColorMode, "color-mode", "auto", "Colors of the terminal: basic, 256, truecolor or auto to detect them.")
+	c.boolVar(&c.Glyphs, "glyphs", false, "Draw letters of shapes in their cells to tell them apart without colors.")
+	c.stringVar(&c.Layout, "layout", "auto", "Layout of the screen: full, compact with half blocks for small terminals, or auto to use compact when full doesn't fit and glyphs are off.")
+	c.boolVar(&c.Sound, "sound", false, "Ring the terminal bell on line clears and game over.")
+	c.intVar(&c.Width, "width", 0, "Width of the field of each player, the default one if zero.")
+	c.intVar(&c.Height, "height", 0, "Height of the field, the default one if zero.")
+	c.intVar(&c.StartLevel, "start-level", 0, "Number of the level to start at, the first one if zero.")
+	c.intVar(&c.EntryDelay, "entry-delay", 0, "Frames before the next block appears, the level's own if zero or -1 for none.")
+	c.intVar(&c.ClearDelay, "clear-delay", 0, "Frames cleared lines take to disappear, the level's own if zero or -1 for none.")
+	return c
+}
+
+func (c *Config) add(name string) {
+	c.names = append(c.names, name)
+	c.sources[name] = SourceDefault
+}
+
+func (c *Config) boolVar(p *bool, name string, value bool, usage string) {
+	c.flags.BoolVar(p, name, value, usage)
+	c.add(name)
+}
+
+func (c *Config) intVar(p *int, name string, value int, usage string) {
+	c.flags.IntVar(p, name, value, usage)
+	c.add(name)
+}
+
+func (c *Config) int64Var(p *int64, name string, value int64, usage string) {
+	c.flags.Int64Var(p, name, value, usage)
+	c.add(name)
+}
+
+func (c *Config) stringVar(p *string, name string, value string, usage string) {
+	c.flags.StringVar(p, name, value, usage)
+	c.add(name)
+}
+
+// Set sets the option name to value from src described by origin unless
+// it was already set from a source taking precedence.
+func (c *Config) Set(name, value string, src Source, origin string) error {
+	current, ok := c.sources[name]
+	if !ok {
+		return fmt.Errorf("unknown option %q", name)
+	}
+	if src < current {
+		return nil
+	}
+
+	// Setting the value of the flag directly keeps it from counting as
+	// set on the command line.
+	err := c.flags.Lookup(name).Value.Set(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s", value, name)
+	}
+	c.sources[name] = src
+	c.origins[name] = origin
+	return nil
+}
+
+// Flag registers the option name as a flag of fs too, for flags given
+// after a command. Flags of fs must be parsed with ParseFlags.
+func (c *Config) Flag(fs *flag.FlagSet, name string) {
+	f := c.flags.Lookup(name)
+	fs.Var(f.Value, name, f.Usage)
+}
+
+// Source returns where the value of the option name comes from.
+func (c *Config) Source(name string) Source {
+	return c.sources[name]
+}
+
+// Path returns the path of the config file.
+func Path() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	return filepath.Join(xdg.ConfigHome(), FileName)
+}
+
+// LoadFile sets options from the config file at path. A missing file is
+// the same as an empty one.
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return fmt.Errorf("%s:%d: expected name = value", path, n)
+		}
+		name := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+		err := c.Set(name, value, SourceFile, fmt.Sprintf("%s:%d", path, n))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// EnvName returns the environment variable setting the option name.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// LoadEnv sets options from environment variables.
+func (c *Config) LoadEnv() error {
+	for _, name := range c.names {
+		env := EnvName(name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		err := c.Set(name, value, SourceEnv, env)
+		if err != nil {
+			return fmt.Errorf("%s: %s", env, err)
+		}
+	}
+	return nil
+}
+
+// Parse parses flags in args, marking options set by them.
+func (c *Config) Parse(args []string) error {
+	return c.ParseFlags(c.flags, args)
+}
+
+// ParseFlags parses flags of fs in args like Parse.
+func (c *Config) ParseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := c.sources[f.Name]; ok {
+			c.sources[f.Name] = SourceFlag
+			c.origins[f.Name] = "-" + f.Name
+		}
+	})
+	return nil
+}
+
+// Load returns a config with options registered as flags of fs and set
+// from the config file, the environment and flags in args.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := New(fs)
+	err := c.LoadFile(Path())
+	if err != nil {
+		return nil, err
+	}
+	err = c.LoadEnv()
+	if err != nil {
+		return nil, err
+	}
+	return c, c.Parse(args)
+}
+
+// Write writes the effective config to w in the format of the config
+// file, noting where each value comes from.
+func (c *Config) Write(w io.Writer) error {
+	for _, name := range c.names {
+		origin := c.sources[name].String()
+		if o := c.origins[name]; o != "" {
+			origin += " " + o
+		}
+		line := fmt.Sprintf("%s = %s", name, c.flags.Lookup(name).Value)
+		_, err := fmt.Fprintf(w, "%-40s # %s\n", line, origin)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package game
+
+const (
+	AnimationLineClear = iota
+	AnimationLock
+
+	// Lines cleared without a delay flash in place for a few frames
+	// after they are removed.
+	AnimationLineFlash
+
+	LockFlashFrames = 6
+	LineFlashFrames = 8
+)
+
+type Point struct {
+	X int
+	Y int
+}
+
+type Animation struct {
+	Kind   int
+	Lines  []int
+	Cells  []Point
+	Frame  int
+	Frames int
+}
+
+func (a *Animation) Finished() bool {
+	return a.Frame >= a.Frames
+}
+
+func NewLineClearAnimation(lines []int, frames int) *Animation {
+	return &Animation{
+		Kind:   AnimationLineClear,
+		Lines:  lines,
+		Frames: frames,
+	}
+}
+
+func NewLineFlashAnimation(lines []int) *Animation {
+	return &Animation{
+		Kind:   AnimationLineFlash,
+		Lines:  lines,
+		Frames: LineFlashFrames,
+	}
+}
+
+func NewLockAnimation(cells []Point) *Animation {
+	return &Animation{
+		Kind:   AnimationLock,
+		Cells:  cells,
+		Frames: LockFlashFrames,
+	}
+}
+
+func advanceAnimations(animations []*Animation) []*Animation {
+	active := animations[:0]
+	for _, a := range animations {
+		a.Frame++
+		if !a.Finished() {
+			active = append(active, a)
+		}
+	}
+	return active
+}
@@ -0,0 +1,380 @@
+package game
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	ANSIDefaultWidth  = 80
+	ANSIDefaultHeight = 24
+
+	ansiEnter = "\x1b[?1049h\x1b[?25l\x1b[2J"
+	ansiLeave = "\x1b[0m\x1b[?25h\x1b[?1049l"
+
+	ansiEvents = 64
+)
+
+var (
+	// ansiKeys are keys of CSI and SS3 sequences by their final byte,
+	// whatever their parameters, ansiTildeKeys keys of CSI sequences
+	// ending with ~ by their first parameter, and ansiConsoleKeys keys
+	// of the Linux console's ESC [ [ sequences.
+	ansiKeys = map[byte]termbox.Key{
+		'A': termbox.KeyArrowUp,
+		'B': termbox.KeyArrowDown,
+		'C': termbox.KeyArrowRight,
+		'D': termbox.KeyArrowLeft,
+		'H': termbox.KeyHome,
+		'F': termbox.KeyEnd,
+		'P': termbox.KeyF1,
+		'Q': termbox.KeyF2,
+		'R': termbox.KeyF3,
+		'S': termbox.KeyF4,
+	}
+	ansiTildeKeys = map[string]termbox.Key{
+		"1":  termbox.KeyHome,
+		"2":  termbox.KeyInsert,
+		"3":  termbox.KeyDelete,
+		"4":  termbox.KeyEnd,
+		"5":  termbox.KeyPgup,
+		"6":  termbox.KeyPgdn,
+		"7":  termbox.KeyHome,
+		"8":  termbox.KeyEnd,
+		"11": termbox.KeyF1,
+		"12": termbox.KeyF2,
+		"13": termbox.KeyF3,
+		"14": termbox.KeyF4,
+		"15": termbox.KeyF5,
+		"17": termbox.KeyF6,
+		"18": termbox.KeyF7,
+		"19": termbox.KeyF8,
+		"20": termbox.KeyF9,
+		"21": termbox.KeyF10,
+		"23": termbox.KeyF11,
+		"24": termbox.KeyF12,
+	}
+	ansiConsoleKeys = map[byte]termbox.Key{
+		'A': termbox.KeyF1,
+		'B': termbox.KeyF2,
+		'C': termbox.KeyF3,
+		'D': termbox.KeyF4,
+		'E': termbox.KeyF5,
+	}
+)
+
+type ansiCell struct {
+	ch rune
+	fg termbox.Attribute
+	bg termbox.Attribute
+}
+
+// ANSITerminal draws to an arbitrary writer with ANSI escape sequences.
+// Input is fed as raw bytes and turned into termbox events, so the
+// controller can't tell it from the terminal of the process.
+type ANSITerminal struct {
+	w      *bufio.Writer
+	width  int
+	height int
+	back   []ansiCell
+	front  []ansiCell
+	events chan termbox.Event
+	input  []byte
+	closed bool
+	mu     sync.Mutex
+}
+
+func (t *ANSITerminal) resize(width, height int) {
+	t.width = width
+	t.height = height
+	t.back = make([]ansiCell, width*height)
+	t.front = make([]ansiCell, width*height)
+	for i := range t.front {
+		t.front[i].ch = -1
+	}
+}
+
+func (t *ANSITerminal) Init() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.w.WriteString(ansiEnter)
+	return t.w.Flush()
+}
+
+func (t *ANSITerminal) close() {
+	if !t.closed {
+		t.closed = true
+		close(t.events)
+	}
+}
+
+func (t *ANSITerminal) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.closed {
+		t.w.WriteString(ansiLeave)
+		t.w.Flush()
+	}
+	t.close()
+}
+
+// CloseInput makes the game see the terminal as closed, e.g. after the
+// client disconnected.
+func (t *ANSITerminal) CloseInput() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.close()
+}
+
+func (t *ANSITerminal) Size() (int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.width, t.height
+}
+
+func (t *ANSITerminal) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if x < 0 || x >= t.width || y < 0 || y >= t.height {
+		return
+	}
+	t.back[y*t.width+x] = ansiCell{ch: ch, fg: fg, bg: bg}
+}
+
+func (t *ANSITerminal) SetCursor(x, y int) {}
+
+func (t *ANSITerminal) Clear(fg, bg termbox.Attribute) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.back {
+		t.back[i] = ansiCell{ch: ' ', fg: fg, bg: bg}
+	}
+	return nil
+}
+
+func ansiColor(attr termbox.Attribute, base int) string {
+	color := int(attr & 0x1ff)
+	switch {
+	case color == 0:
+		return fmt.Sprint(base + 9)
+	case color <= 8:
+		return fmt.Sprint(base + color - 1)
+	case color <= 16:
+		return fmt.Sprint(base + 60 + color - 9)
+	}
+	return fmt.Sprintf("%d;5;%d", base+8, color-1)
+}
+
+func ansiStyle(c ansiCell) string {
+	style := "\x1b[0"
+	if c.fg&termbox.AttrBold != 0 {
+		style += ";1"
+	}
+	if c.fg&termbox.AttrUnderline != 0 {
+		style += ";4"
+	}
+	if c.fg&termbox.AttrReverse != 0 || c.bg&termbox.AttrReverse != 0 {
+		style += ";7"
+	}
+	return style + ";" + ansiColor(c.fg, 30) + ";" + ansiColor(c.bg, 40) + "m"
+}
+
+// Flush writes cells changed since the last flush. Flushing a closed
+// terminal does nothing and write errors close the terminal, so games
+// of disconnected clients simply exit.
+func (t *ANSITerminal) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+
+	style := ""
+	for i, c := range t.back {
+		if c == t.front[i] {
+			continue
+		}
+		t.front[i] = c
+
+		fmt.Fprintf(t.w, "\x1b[%d;%dH", i/t.width+1, i%t.width+1)
+		if s := ansiStyle(c); s != style {
+			style = s
+			t.w.WriteString(s)
+		}
+		if c.ch == 0 {
+			c.ch = ' '
+		}
+		t.w.WriteRune(c.ch)
+	}
+
+	err := t.w.Flush()
+	if err != nil {
+		t.close()
+	}
+	return nil
+}
+
+func (t *ANSITerminal) PollEvent() termbox.Event {
+	e, ok := <-t.events
+	if !ok {
+		return termbox.Event{Type: termbox.EventError, Err: io.EOF}
+	}
+	return e
+}
+
+// SetMouse does nothing since mouse reports of telnet clients aren't
+// parsed.
+func (t *ANSITerminal) SetMouse(enabled bool) {}
+
+func (t *ANSITerminal) Bell() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.closed {
+		t.w.WriteString("\a")
+		t.w.Flush()
+	}
+}
+
+// SetColorMode shows up to 256 colors, which is as many as ansiColor
+// writes.
+func (t *ANSITerminal) SetColorMode(mode ColorMode) ColorMode {
+	if mode > Colors256 {
+		return Colors256
+	}
+	return mode
+}
+
+func (t *ANSITerminal) emit(e termbox.Event) {
+	if !t.closed {
+		select {
+		case t.events <- e:
+		default:
+		}
+	}
+}
+
+// Resize changes the size of the terminal, e.g. after a telnet client
+// reported the size of its window.
+func (t *ANSITerminal) Resize(width, height int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if width <= 0 || height <= 0 {
+		return
+	}
+	t.resize(width, height)
+	t.w.WriteString("\x1b[2J")
+	t.emit(termbox.Event{Type: termbox.EventResize, Width: width, Height: height})
+}
+
+// Feed parses keys typed by the user. Escape sequences split between
+// calls wait for the rest, sequences of unknown keys are dropped and a
+// lone escape is the Esc key.
+func (t *ANSITerminal) Feed(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.input = append(t.input, data...)
+	for len(t.input) > 0 {
+		b := t.input[0]
+		switch {
+		case b == 0x1b:
+			n, key, ok := ansiEscape(t.input)
+			if n == 0 {
+				return
+			}
+			t.input = t.input[n:]
+			if ok {
+				t.emit(termbox.Event{Type: termbox.EventKey, Key: key})
+			}
+		case b == '\r':
+			// Telnet sends a carriage return followed by NUL or LF.
+			t.input = t.input[1:]
+			if len(t.input) > 0 && (t.input[0] == 0 || t.input[0] == '\n') {
+				t.input = t.input[1:]
+			}
+			t.emit(termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEnter})
+		case b == ' ':
+			t.input = t.input[1:]
+			t.emit(termbox.Event{Type: termbox.EventKey, Key: termbox.KeySpace})
+		case b < 0x20 || b == 0x7f:
+			t.input = t.input[1:]
+			t.emit(termbox.Event{Type: termbox.EventKey, Key: termbox.Key(b)})
+		default:
+			if !utf8.FullRune(t.input) {
+				return
+			}
+			ch, n := utf8.DecodeRune(t.input)
+			t.input = t.input[n:]
+			t.emit(termbox.Event{Type: termbox.EventKey, Ch: ch})
+		}
+	}
+}
+
+// ansiEscape parses the escape sequence input starts with and returns
+// its length and key, ok false if the key is unknown and n zero if the
+// sequence isn't complete yet. An escape followed by anything but [ or
+// O is the Esc key.
+func ansiEscape(input []byte) (n int, key termbox.Key, ok bool) {
+	if len(input) < 2 || input[1] != '[' && input[1] != 'O' {
+		return 1, termbox.KeyEsc, true
+	}
+	if len(input) < 3 {
+		return 0, 0, false
+	}
+	if input[1] == 'O' {
+		key, ok = ansiKeys[input[2]]
+		return 3, key, ok
+	}
+	if input[2] == '[' {
+		if len(input) < 4 {
+			return 0, 0, false
+		}
+		key, ok = ansiConsoleKeys[input[3]]
+		return 4, key, ok
+	}
+
+	// CSI sequences have parameter and intermediate bytes followed by a
+	// final byte.
+	for i := 2; i < len(input); i++ {
+		b := input[i]
+		switch {
+		case b >= 0x40 && b <= 0x7e:
+			if b != '~' {
+				key, ok = ansiKeys[b]
+				return i + 1, key, ok
+			}
+			params := string(input[2:i])
+			if j := strings.IndexByte(params, ';'); j >= 0 {
+				params = params[:j]
+			}
+			key, ok = ansiTildeKeys[params]
+			return i + 1, key, ok
+		case b < 0x20 || b > 0x3f:
+			// Not a CSI sequence, drop what was read of it.
+			return i, 0, false
+		}
+	}
+	return 0, 0, false
+}
+
+func NewANSITerminal(w io.Writer) *ANSITerminal {
+	t := &ANSITerminal{
+		w:      bufio.NewWriter(w),
+		events: make(chan termbox.Event, ansiEvents),
+	}
+	t.resize(ANSIDefaultWidth, ANSIDefaultHeight)
+	return t
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"sort"
+
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/netplay"
+)
+
+func (g *Game) setStrategy(strategy string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.strategy = strategy
+	g.screen.strategy = strategy
+	g.conn.Send(&netplay.Message{Type: netplay.MessageTarget, Strategy: strategy})
+	log.Info("Changed targeting strategy to %s.", strategy)
+	g.redraw()
+}
+
+// updateOpponents replaces boards of the other players of the lobby.
+func (g *Game) updateOpponents(players []*netplay.Player) {
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+
+	local := g.boards[0]
+	target := 0
+	for _, p := range players {
+		if p.ID == g.id {
+			local.badges = p.Badges
+			target = p.Target
+		}
+	}
+
+	// Boards are kept by IDs of their players since names of players
+	// may be the same.
+	existing := make(map[int]*Board)
+	for _, b := range g.opponents {
+		existing[b.id] = b
+	}
+
+	g.opponents = g.opponents[:0]
+	for _, p := range players {
+		if p.ID == g.id {
+			continue
+		}
+
+		b, ok := existing[p.ID]
+		if !ok {
+			b = NewBoard(NewField(FieldHeight, FieldWidth), g.mode, false, false)
+			b.remote = true
+			b.id = p.ID
+		}
+		if p.Board != nil {
+			b.Restore(p.Board)
+		} else {
+			b.field.Clear(true)
+		}
+		b.name = p.Name
+		b.badges = p.Badges
+		b.place = p.Place
+		b.finished = !p.Alive
+		b.targeted = p.ID == target
+		b.attacking = p.Alive && p.Target == g.id
+		g.opponents = append(g.opponents, b)
+	}
+	g.screen.opponents = g.opponents
+}
+
+// receiveLobby handles messages of the lobby until the connection is
+// closed. Rounds are started by the lobby.
+func (g *Game) receiveLobby() {
+	for {
+		m, err := g.conn.Receive()
+		if err != nil || m.Type == netplay.MessageBye {
+			g.disconnect()
+			return
+		}
+
+		if m.Type == netplay.MessageStart {
+			log.Info("Round started.")
+			g.start()
+			continue
+		}
+
+		g.mu.Lock()
+		local := g.boards[0]
+		switch m.Type {
+		case netplay.MessageJoin:
+			g.id = m.ID
+		case netplay.MessagePlayers:
+			g.updateOpponents(m.Players)
+		case netplay.MessageGarbage:
+			local.Receive(m.Lines)
+		case netplay.MessageResult:
+			local.place = m.Place
+			g.state = StateFinished
+			log.Info("Finished round at place %d.", m.Place)
+		case netplay.MessageError:
+			log.Error("Lobby error: %s.", m.Text)
+		}
+		g.redraw()
+		g.mu.Unlock()
+	}
+}
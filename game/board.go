@@ -0,0 +1,259 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+var (
+	// Garbage lines sent for clearing 0, 1, 2, 3 and 4 lines at once.
+	garbageLines = []int{0, 0, 1, 2, 4}
+)
+
+// Board is a field shared by one or more players together with its
+// stats, level and line clear state.
+type Board struct {
+	field   *Field
+	stats   *Stats
+	mode    *Mode
+	level   *Level
+	players []*Player
+
+	// Index of the level the board starts at. Later levels are reached
+	// by playing time as usual.
+	startLevel int
+
+	// StateRunning or StateLineClear. Players don't move while lines
+	// are being cleared.
+	state int
+
+	// Frames left in the line clear delay and the lines waiting to be
+	// removed once it is over.
+	delay    int
+	clearing []int
+
+	animations []*Animation
+
+	// Source of random blocks and garbage holes. Boards of a game share
+	// the source seeded by the game so replays are deterministic.
+	rng *rand.Rand
+
+	// Garbage lines waiting to be added to the board and the board
+	// receiving garbage sent by this one in versus mode.
+	pending int
+	target  Target
+
+	// Remote boards are played by the opponent over the network and
+	// are only updated from snapshots.
+	remote       bool
+	disconnected bool
+
+	// ID, name, badges and place of the board's player in a battle
+	// royale lobby and whether the local player targets it or is
+	// attacked by it.
+	id        int
+	name      string
+	badges    int
+	place     int
+	targeted  bool
+	attacking bool
+
+	finished bool
+	fast     bool
+	animate  bool
+
+	// Delays replacing the ones of levels, see Options.
+	entryDelay int
+	clearDelay int
+}
+
+// customDelay returns delay of a level replaced by custom, see Options.
+func customDelay(delay, custom int) int {
+	switch {
+	case custom == NoDelay:
+		return 0
+	case custom > 0:
+		return custom
+	}
+	return delay
+}
+
+func (b *Board) clearLines() {
+	delay := customDelay(b.level.ClearDelay, b.clearDelay)
+	b.clearing = b.field.FilledLines(b.mode.Scale)
+	if len(b.clearing) == 0 {
+		b.addGarbage()
+		return
+	}
+
+	// Animations are drawn within the delay of the level and never
+	// change the timing of the game, so levels clearing lines at once
+	// only flash them.
+	if b.animate {
+		if delay > 0 {
+			b.animations = append(b.animations, NewLineClearAnimation(b.clearing, delay))
+		} else {
+			b.animations = append(b.animations, NewLineFlashAnimation(b.clearing))
+		}
+	}
+	if delay > 0 {
+		b.delay = delay
+		b.state = StateLineClear
+		return
+	}
+	b.removeLines()
+}
+
+func (b *Board) removeLines() {
+	// Lines of big blocks are cleared in groups but scored as one.
+	removed := b.field.RemoveLines(b.clearing) / b.mode.Scale
+	b.clearing = nil
+	if removed > 0 {
+		b.stats.Score += b.level.LinePoints * int(math.Pow(2, float64(removed)))
+		b.stats.Lines += removed
+		if removed <= len(b.stats.Clears) {
+			b.stats.Clears[removed-1]++
+		}
+		b.attack(removed)
+	}
+
+	// Blocks of other players may be caught by the collapsed stack.
+	for _, p := range b.players {
+		if p.curBlock != nil && !p.curBlock.Raise(b.field) {
+			b.finish()
+		}
+	}
+}
+
+// attack cancels pending garbage with lines sent for removing lines and
+// sends the rest to the target board.
+func (b *Board) attack(removed int) {
+	if removed >= len(garbageLines) {
+		removed = len(garbageLines) - 1
+	}
+	lines := garbageLines[removed]
+
+	canceled := lines
+	if canceled > b.pending {
+		canceled = b.pending
+	}
+	b.pending -= canceled
+	lines -= canceled
+
+	if lines > 0 && b.target != nil {
+		b.stats.GarbageSent += lines
+		b.target.Receive(lines)
+		log.Debug("Sent %d garbage lines.", lines)
+	}
+}
+
+func (b *Board) Receive(lines int) {
+	b.pending += lines
+}
+
+func (b *Board) addGarbage() {
+	if b.pending == 0 {
+		return
+	}
+
+	hole := b.rng.Intn(b.field.Width)
+	overflow := b.field.AddGarbage(b.pending, hole)
+	b.stats.GarbageReceived += b.pending
+	log.Debug("Received %d garbage lines.", b.pending)
+	b.pending = 0
+
+	for _, p := range b.players {
+		if p.curBlock != nil && !p.curBlock.Raise(b.field) {
+			overflow = true
+		}
+	}
+	if overflow {
+		b.finish()
+	}
+}
+
+func (b *Board) tryChangeLevel() {
+	for i, level := range b.mode.Levels {
+		elapsed := int(b.stats.Elapsed)
+		if b.fast {
+			elapsed *= FastGameMultiplier
+		}
+		if i <= b.startLevel || level.StartsAfter <= elapsed {
+			b.level = level
+		}
+	}
+	log.Info("Changed level to %s.", b.level.Name)
+	b.stats.Level = b.level.Name
+}
+
+func (b *Board) finish() {
+	b.finished = true
+	log.Info("Board is finished.")
+}
+
+func (b *Board) tick() {
+	if b.remote {
+		return
+	}
+
+	b.stats.Elapsed += 1.0 / FramesPerSecond
+	b.animations = advanceAnimations(b.animations)
+	switch b.state {
+	case StateLineClear:
+		b.delay--
+		if b.delay <= 0 {
+			b.state = StateRunning
+			b.removeLines()
+		}
+	case StateRunning:
+		for _, p := range b.players {
+			p.tick()
+		}
+	}
+}
+
+func (b *Board) redraw() {
+	b.field.Clear(false)
+	for _, p := range b.players {
+		if p.curBlock != nil {
+			p.curBlock.MustDraw(b.field, false)
+		}
+	}
+}
+
+func (b *Board) start() {
+	*b.stats = Stats{Pieces: make(map[string]int)}
+
+	b.level = b.mode.Levels[b.startLevel]
+	b.state = StateRunning
+	b.delay = 0
+	b.clearing = nil
+	b.animations = nil
+	b.pending = 0
+	b.finished = false
+	b.disconnected = false
+	b.place = 0
+	b.field.Clear(true)
+	for _, p := range b.players {
+		p.reset()
+	}
+	for _, p := range b.players {
+		p.spawn()
+	}
+}
+
+func NewBoard(field *Field, mode *Mode, fast, animate bool) *Board {
+	return &Board{
+		field:   field,
+		stats:   &Stats{Pieces: make(map[string]int)},
+		mode:    mode,
+		level:   mode.Levels[0],
+		state:   StateRunning,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		fast:    fast,
+		animate: animate,
+	}
+}
@@ -0,0 +1,182 @@
+package game
+
+import (
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+const (
+	// Bots take an action every BotDelay frames.
+	BotDelay = 4
+)
+
+const (
+	botRotate = iota
+	botLeft
+	botRight
+	botDrop
+)
+
+// Weights of the field features used by bots to rate placements.
+const (
+	botHeightWeight    = -0.51
+	botLinesWeight     = 0.76
+	botHolesWeight     = -0.36
+	botBumpinessWeight = -0.18
+)
+
+// Bot plays for a player by placing every block where it leaves the
+// best rated field.
+type Bot struct {
+	player  *Player
+	block   *Block
+	actions []int
+	frame   int
+}
+
+func (f *Field) copy() *Field {
+	c := NewField(f.Height, f.Width)
+	for i := 0; i < f.Height; i++ {
+		copy(c.cells[i], f.cells[i])
+	}
+	return c
+}
+
+func (b *Block) clone() *Block {
+	c := *b
+	c.mask = make([][]byte, len(b.mask))
+	for i := range b.mask {
+		c.mask[i] = make([]byte, len(b.mask[i]))
+		copy(c.mask[i], b.mask[i])
+	}
+	return &c
+}
+
+func rateField(f *Field, lines int) float64 {
+	heights := make([]int, f.Width)
+	holes := 0
+	for j := 0; j < f.Width; j++ {
+		for i := 0; i < f.Height; i++ {
+			if f.cells[i][j].value == FixedCellValue {
+				if heights[j] == 0 {
+					heights[j] = f.Height - i
+				}
+			} else if heights[j] > 0 {
+				holes++
+			}
+		}
+	}
+
+	height, bumpiness := 0, 0
+	for j, h := range heights {
+		height += h
+		if j > 0 {
+			d := h - heights[j-1]
+			if d < 0 {
+				d = -d
+			}
+			bumpiness += d
+		}
+	}
+
+	return botHeightWeight*float64(height) +
+		botLinesWeight*float64(lines) +
+		botHolesWeight*float64(holes) +
+		botBumpinessWeight*float64(bumpiness)
+}
+
+// plan finds the best placement of the current block and the actions
+// leading to it.
+func (bot *Bot) plan() {
+	p := bot.player
+	field := p.board.field
+	others := p.others()
+
+	best := 0.0
+	bot.actions = nil
+	for rotations := 0; rotations < 4; rotations++ {
+		for dx := -field.Width; dx <= field.Width; dx++ {
+			block := p.curBlock.clone()
+			ok := true
+			for r := 0; r < rotations && ok; r++ {
+				ok = block.TryRotate(field, others...)
+			}
+
+			moved := 0
+			step := 1
+			if dx < 0 {
+				step = -1
+			}
+			for moved != dx && ok {
+				ok = block.TryMove(step, 0, field, others...)
+				if ok {
+					moved += step
+				}
+			}
+			if !ok {
+				continue
+			}
+			for block.TryMove(0, 1, field, others...) {
+			}
+
+			f := field.copy()
+			f.Clear(false)
+			block.MustDraw(f, true)
+			lines := f.RemoveLines(f.FilledLines(p.board.mode.Scale))
+			score := rateField(f, lines)
+			if bot.actions == nil || score > best {
+				best = score
+				bot.actions = bot.actions[:0]
+				for r := 0; r < rotations; r++ {
+					bot.actions = append(bot.actions, botRotate)
+				}
+				for i := 0; i != dx; i += step {
+					if step < 0 {
+						bot.actions = append(bot.actions, botLeft)
+					} else {
+						bot.actions = append(bot.actions, botRight)
+					}
+				}
+			}
+		}
+	}
+	log.Debug("Bot planned %d actions.", len(bot.actions))
+}
+
+// act takes the next action for the current block, dropping it once
+// the block is in place.
+func (bot *Bot) act() {
+	p := bot.player
+	if p.curBlock == nil || p.state != StateRunning || p.board.state != StateRunning {
+		return
+	}
+	if p.curBlock != bot.block {
+		bot.block = p.curBlock
+		bot.plan()
+	}
+
+	bot.frame++
+	if bot.frame%BotDelay != 0 {
+		return
+	}
+
+	action := botDrop
+	if len(bot.actions) > 0 {
+		action = bot.actions[0]
+		bot.actions = bot.actions[1:]
+	}
+
+	switch action {
+	case botRotate:
+		p.TryRotate()
+	case botLeft:
+		p.TryMove(-1, 0)
+	case botRight:
+		p.TryMove(1, 0)
+	case botDrop:
+		p.moveDown()
+	}
+}
+
+func NewBot(player *Player) *Bot {
+	return &Bot{player: player}
+}
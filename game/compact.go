@@ -0,0 +1,310 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Layouts of the screen. The compact layout draws fields with one
+// terminal cell per field cell and two rows of cells per line using
+// half blocks, and collapses prompts around the boards into a few
+// lines so games fit into 80x24 terminals and smaller panes. Half
+// blocks leave no room for glyphs, so they are drawn only in the full
+// layout, which the auto layout keeps when glyphs are on.
+const (
+	LayoutAuto    = "auto"
+	LayoutFull    = "full"
+	LayoutCompact = "compact"
+)
+
+// LayoutNames are names of the layouts in the order they are offered in
+// the settings screen.
+var LayoutNames = []string{LayoutAuto, LayoutFull, LayoutCompact}
+
+const (
+	CompactTop         = 1
+	CompactPromptWidth = 13
+	CompactPromptLeft  = 1
+	CompactBoxChars    = "||"
+	CompactGhostColor  = termbox.ColorLightGray
+
+	// Lines below the boards with the keys moving blocks, the state of
+	// battles and replays and keys of the most useful actions, wrapped
+	// once at most.
+	CompactStatusLines = 4
+	CompactStatusWidth = 48
+	CompactHelpGap     = 2
+
+	// High scores are drawn over the whole layout, at least as large
+	// as their table.
+	CompactScoresWidth = 53
+	CompactScoresLines = 15
+
+	CompactStatsPrompt = "" +
+		"Level %6s\n" +
+		"Time  %6d\n" +
+		"Lines %6d\n" +
+		"Score %6d"
+	CompactNextPrompt     = "Next:"
+	CompactMovePrompt     = "Move %s %s  drop %s  rotate %s"
+	CompactMovePrompt2    = "  Left player %s %s %s %s"
+	CompactBattlePrompt   = "Target: %s  Badges: %d  Alive: %d/%d"
+	CompactPlaybackPrompt = "%s  %gx  frame %d/%d  piece %d"
+)
+
+var (
+	CompactHelpPrompt = []HelpLine{
+		{"pause", "pause"},
+		{"restart", "new-game"},
+		{"save", "save"},
+		{"scores", "scores"},
+		{"close", "exit"},
+	}
+	CompactBattleHelpPrompt = []HelpLine{
+		{"random", "target-random"},
+		{"attackers", "target-attackers"},
+		{"KO", "target-ko"},
+		{"close", "exit"},
+	}
+	CompactPlaybackHelpPrompt = []HelpLine{
+		{"pause", "pause"},
+		{"faster", "speed-up"},
+		{"slower", "slow-down"},
+		{"step", "step"},
+		{"prev", "prev-piece"},
+		{"next", "next-piece"},
+		{"close", "exit"},
+	}
+)
+
+// compactCells returns terminal colors of cells of the board with its
+// ghost pieces and animations, ColorDefault for empty cells.
+func (s *Screen) compactCells(b *Board) [][]termbox.Attribute {
+	cells := make([][]termbox.Attribute, b.field.Height)
+	for i := range cells {
+		cells[i] = make([]termbox.Attribute, b.field.Width)
+		for j := range cells[i] {
+			_, color, _ := b.field.Get(j, i)
+			cells[i][j] = s.theme[color]
+		}
+	}
+	set := func(x, y int, color termbox.Attribute) {
+		if y >= 0 && y < len(cells) && x >= 0 && x < len(cells[y]) {
+			cells[y][x] = color
+		}
+	}
+
+	if s.ghost {
+		for _, p := range b.players {
+			for _, cell := range s.ghostCells(p) {
+				set(cell.X, cell.Y, CompactGhostColor)
+			}
+		}
+	}
+	s.animate(b, set)
+	return cells
+}
+
+// drawHalfBlocks draws cells with two rows per line using upper and
+// lower half blocks.
+func (s *Screen) drawHalfBlocks(left, top int, cells [][]termbox.Attribute) {
+	for i := 0; i < len(cells); i += 2 {
+		for j, upper := range cells[i] {
+			lower := termbox.ColorDefault
+			if i+1 < len(cells) {
+				lower = cells[i+1][j]
+			}
+
+			switch {
+			case upper == termbox.ColorDefault && lower == termbox.ColorDefault:
+				continue
+			case upper == termbox.ColorDefault:
+				s.term.SetCell(left+j, top+i/2, '▄', lower, BackgroundColor)
+			default:
+				s.term.SetCell(left+j, top+i/2, '▀', upper, lower)
+			}
+		}
+	}
+}
+
+func (s *Screen) drawCompactBoard(left int, b *Board) {
+	height := (b.field.Height + 1) / 2
+	sides := []rune(CompactBoxChars)
+	for i := 0; i <= height; i++ {
+		s.term.SetCell(left, s.Top+i, sides[0], FieldBoxColor, BackgroundColor)
+		s.term.SetCell(left+b.field.Width+1, s.Top+i, sides[1], FieldBoxColor, BackgroundColor)
+	}
+	for j := 0; j < b.field.Width; j++ {
+		s.term.SetCell(left+1+j, s.Top+height, FieldBoxBottomChar, FieldBoxColor, BackgroundColor)
+	}
+
+	// Pending garbage is shown as a meter growing up the left wall,
+	// a line for every two garbage lines.
+	for i := 0; i < (b.pending+1)/2 && i < height; i++ {
+		s.term.SetCell(left, s.Top+height-1-i, sides[0], FieldBoxColor, PendingGarbageColor)
+	}
+
+	left++
+	s.fields = append(s.fields, fieldArea{left, b})
+	s.drawHalfBlocks(left, s.Top, s.compactCells(b))
+}
+
+func (s *Screen) drawCompactStats(left int, state int, b *Board) {
+	left += CompactPromptLeft
+	top := s.Top
+	s.drawState(left, top, state, b)
+
+	stats := b.stats
+	str := fmt.Sprintf(
+		CompactStatsPrompt,
+		stats.Level, int(stats.Elapsed), stats.Lines, stats.Score,
+	)
+	s.drawString(left, top+2, str, termbox.ColorDefault)
+
+	if len(b.players) == 0 {
+		return
+	}
+	top += 2 + strings.Count(CompactStatsPrompt, "\n") + 2
+	s.drawString(left, top, CompactNextPrompt, termbox.ColorDefault)
+
+	// Previews take a line each and a line between them, as many of
+	// them as fit next to the board.
+	top++
+	height := (PreviewHeight+1)/2 + 1
+	shown := (s.Top + s.boardLines() - top + 1) / (height * len(b.players))
+	if n := len(b.players[0].previews); n < shown {
+		shown = n
+	}
+	for i, p := range b.players {
+		for j, preview := range p.previews {
+			if j < shown {
+				cells := make([][]termbox.Attribute, preview.Height)
+				for y := range cells {
+					cells[y] = make([]termbox.Attribute, preview.Width)
+					for x := range cells[y] {
+						_, color, _ := preview.Get(x, y)
+						cells[y][x] = s.theme[color]
+					}
+				}
+				s.drawHalfBlocks(left+1, top+(i*shown+j)*height, cells)
+			}
+		}
+	}
+}
+
+// drawCompactHelp draws keys of actions of lines in a single line
+// wrapped at width. Every action is a button.
+func (s *Screen) drawCompactHelp(left, top, width int, lines []HelpLine) {
+	x := left
+	for _, line := range lines {
+		str := fmt.Sprintf("%s %s", s.keys.Label(line.Action), line.Label)
+		n := len([]rune(str))
+		if x > left && x+n > left+width {
+			x = left
+			top++
+		}
+		s.drawString(x, top, str, termbox.ColorDefault)
+		s.buttons = append(s.buttons, button{x, top, n, line.Action})
+		x += n + CompactHelpGap
+	}
+}
+
+// compactMoveKeys returns the line with the keys moving blocks, with
+// the ones of the left player when two players share the terminal.
+func (s *Screen) compactMoveKeys() string {
+	k := s.keys
+	str := fmt.Sprintf(
+		CompactMovePrompt,
+		k.Label("left"), k.Label("right"), k.Label("drop"), k.Label("rotate"),
+	)
+	players := 0
+	for _, b := range s.boards {
+		players += len(b.players)
+	}
+	if players > 1 {
+		str += fmt.Sprintf(
+			CompactMovePrompt2,
+			k.Label("left2"), k.Label("right2"), k.Label("drop2"), k.Label("rotate2"),
+		)
+	}
+	return str
+}
+
+// drawCompactStatus draws the keys moving blocks, the state of battles
+// and replays and keys of their actions below the boards.
+func (s *Screen) drawCompactStatus() {
+	left := s.Left + CompactPromptLeft
+	top := s.Top + s.height() - CompactStatusLines
+
+	// Status lines may be wider than a single board, as long as they
+	// fit into the terminal.
+	width := s.width()
+	if width < CompactStatusWidth {
+		width = CompactStatusWidth
+	}
+	if w, _ := s.term.Size(); width > w-s.Left {
+		width = w - s.Left
+	}
+	width -= CompactPromptLeft
+
+	if s.playback == nil {
+		s.drawString(left, top, s.compactMoveKeys(), termbox.ColorDefault)
+		top++
+	}
+
+	lines := CompactHelpPrompt
+	switch {
+	case s.battle:
+		target, alive := "-", 0
+		for _, b := range s.opponents {
+			if b.targeted {
+				target = b.name
+			}
+			if !b.finished {
+				alive++
+			}
+		}
+		if !s.boards[0].finished {
+			alive++
+		}
+		str := fmt.Sprintf(
+			CompactBattlePrompt, target, s.boards[0].badges,
+			alive, len(s.opponents)+1,
+		)
+		s.drawString(left, top, str, termbox.ColorDefault)
+		top++
+		lines = CompactBattleHelpPrompt
+	case s.playback != nil:
+		pb := s.playback
+		str := fmt.Sprintf(
+			CompactPlaybackPrompt, pb.rep.Name, PlaybackSpeeds[pb.speed],
+			pb.frame, pb.rep.Frames, s.boards[0].stats.Blocks,
+		)
+		s.drawString(left, top, str, termbox.ColorDefault)
+		top++
+		lines = CompactPlaybackHelpPrompt
+	}
+	s.drawCompactHelp(left, top, width, lines)
+}
+
+// drawCompact draws the compact layout: stats, board and, in two board
+// modes, board and stats again or mini boards of opponents in battles,
+// with status lines below.
+func (s *Screen) drawCompact(state int) {
+	left := s.Left
+	s.drawCompactStats(left, state, s.boards[0])
+	left += CompactPromptWidth
+	s.drawCompactBoard(left, s.boards[0])
+	left += s.boardWidth(s.boards[0])
+
+	if s.battle {
+		s.drawOpponents(left + MiniBoardGap)
+	} else if len(s.boards) > 1 {
+		s.drawCompactBoard(left, s.boards[1])
+		left += s.boardWidth(s.boards[1])
+		s.drawCompactStats(left, state, s.boards[1])
+	}
+	s.drawCompactStatus()
+}
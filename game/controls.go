@@ -1,6 +1,8 @@
 package game
 
 import (
+    "sync"
+
     "github.com/nsf/termbox-go"
 )
 
@@ -13,16 +15,66 @@ const (
     EventNewGame
     EventPauseResume
     EventResize
+    EventDown2
+    EventUp2
+    EventLeft2
+    EventRight2
+    EventTargetRandom
+    EventTargetAttackers
+    EventTargetKO
+    EventSpeedUp
+    EventSlowDown
+    EventStep
+    EventNextPiece
+    EventPrevPiece
+    EventSave
+    EventScores
 )
 
-func NewController() *Controller {
+// KeySet is a set of events controlling a single player.
+type KeySet struct {
+    Left   int
+    Right  int
+    Rotate int
+    Drop   int
+}
+
+var (
+    ArrowKeys = KeySet{
+        Left:   EventLeft,
+        Right:  EventRight,
+        Rotate: EventUp,
+        Drop:   EventDown,
+    }
+    WASDKeys = KeySet{
+        Left:   EventLeft2,
+        Right:  EventRight2,
+        Rotate: EventUp2,
+        Drop:   EventDown2,
+    }
+)
+
+func NewController(term Terminal) *Controller {
     return &Controller{
+        term:     term,
         handlers: make(map[int]func()),
+        keys:     DefaultKeyMap().events(),
     }
 }
 
 type Controller struct {
+    term     Terminal
     handlers map[int]func()
+
+    // Keys are events sent for keys by name.
+    keys map[string]int
+
+    // Input receives key events instead of handlers while text is
+    // being typed, e.g. a name for the high score table. Mouse receives
+    // mouse events if set.
+    input func(e termbox.Event)
+    mouse func(e termbox.Event)
+    mu    sync.Mutex
 }
 
 func (c *Controller) handle(event int) {
@@ -36,33 +88,85 @@ func (c *Controller) RegisterHandler(event int, handler func()) {
     c.handlers[event] = handler
 }
 
+// SetInput makes input receive key events until it is set to nil.
+func (c *Controller) SetInput(input func(e termbox.Event)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.input = input
+}
+
+// SetKeyMap makes keys send events of the actions they are bound to in
+// km.
+func (c *Controller) SetKeyMap(km KeyMap) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.keys = km.events()
+}
+
+// SetMouse makes mouse receive mouse events until it is set to nil.
+func (c *Controller) SetMouse(mouse func(e termbox.Event)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.mouse = mouse
+}
+
+func (c *Controller) click(e termbox.Event) {
+    c.mu.Lock()
+    mouse := c.mouse
+    c.mu.Unlock()
+
+    if mouse != nil {
+        mouse(e)
+    }
+}
+
+func (c *Controller) typing(e termbox.Event) bool {
+    c.mu.Lock()
+    input := c.input
+    c.mu.Unlock()
+
+    if input == nil || e.Type != termbox.EventKey {
+        return false
+    }
+    if e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD {
+        return false
+    }
+    input(e)
+    return true
+}
+
 func (c *Controller) Run() {
     for {
-        e := termbox.PollEvent()
+        e := c.term.PollEvent()
+        if e.Type == termbox.EventError {
+            c.handle(EventExit)
+            return
+        }
+
         if e.Type == termbox.EventResize {
             c.handle(EventResize)
         }
 
-        if e.Type == termbox.EventKey {
-            switch e.Ch {
-            case 'p':
-                c.handle(EventPauseResume)
-            case 'n':
-                c.handle(EventNewGame)
-            }
+        if e.Type == termbox.EventMouse {
+            c.click(e)
+            continue
         }
 
-        switch e.Key {
-        case termbox.KeyArrowUp:
-            c.handle(EventUp)
-        case termbox.KeyArrowLeft:
-            c.handle(EventLeft)
-        case termbox.KeyArrowRight:
-            c.handle(EventRight)
-        case termbox.KeyArrowDown:
-            c.handle(EventDown)
-        case termbox.KeyCtrlC, termbox.KeyEsc, termbox.KeyCtrlD:
-            c.handle(EventExit)
+        if c.typing(e) {
+            continue
+        }
+
+        if e.Type == termbox.EventKey {
+            if KeyName(e) == ExitKey {
+                c.handle(EventExit)
+                continue
+            }
+            c.mu.Lock()
+            event, ok := c.keys[KeyName(e)]
+            c.mu.Unlock()
+            if ok {
+                c.handle(event)
+            }
         }
     }
 }
@@ -10,6 +10,10 @@ const (
 	FixedCellValue
 )
 
+const (
+	GarbageCellColor uint16 = 8
+)
+
 var (
 	IndexOutOfBoundsError = errors.New("out of field bounds")
 )
@@ -53,26 +57,70 @@ func (f *Field) Clear(full bool) {
 	}
 }
 
-func (f *Field) RemoveFilledLines() (removed int) {
-	for i := 1; i < f.Height; i++ {
+// FilledLines returns rows of every filled group of size adjacent rows.
+// Groups are aligned to the top of the field and the topmost group is
+// never reported.
+func (f *Field) FilledLines(size int) (lines []int) {
+	for i := size; i+size <= f.Height; i += size {
 		full := true
-		for j := 0; j < f.Width; j++ {
-			if f.cells[i][j].value == EmptyCellValue {
-				full = false
+		for k := i; k < i+size; k++ {
+			for j := 0; j < f.Width; j++ {
+				if f.cells[k][j].value == EmptyCellValue {
+					full = false
+				}
 			}
 		}
 		if full {
-			for k := i; k > 0; k-- {
-				for j := 0; j < f.Width; j++ {
-					f.cells[k][j] = f.cells[k-1][j]
-				}
+			for k := i; k < i+size; k++ {
+				lines = append(lines, k)
 			}
-			removed++
 		}
 	}
+	return lines
+}
+
+func (f *Field) RemoveLines(lines []int) (removed int) {
+	for _, i := range lines {
+		for k := i; k > 0; k-- {
+			for j := 0; j < f.Width; j++ {
+				f.cells[k][j] = f.cells[k-1][j]
+			}
+		}
+		removed++
+	}
 	return removed
 }
 
+func (f *Field) RemoveFilledLines() (removed int) {
+	return f.RemoveLines(f.FilledLines(1))
+}
+
+// AddGarbage pushes the stack up by lines rows of garbage with a hole at
+// the given column. It reports whether fixed cells were pushed out of
+// the field.
+func (f *Field) AddGarbage(lines, hole int) (overflow bool) {
+	for n := 0; n < lines; n++ {
+		for j := 0; j < f.Width; j++ {
+			if f.cells[0][j].value == FixedCellValue {
+				overflow = true
+			}
+		}
+		for i := 0; i < f.Height-1; i++ {
+			for j := 0; j < f.Width; j++ {
+				f.cells[i][j] = f.cells[i+1][j]
+			}
+		}
+		for j := 0; j < f.Width; j++ {
+			if j == hole {
+				f.cells[f.Height-1][j] = Cell{value: EmptyCellValue}
+			} else {
+				f.cells[f.Height-1][j] = Cell{value: FixedCellValue, color: GarbageCellColor}
+			}
+		}
+	}
+	return overflow
+}
+
 func NewField(height, width int) *Field {
 	f := Field{
 		Width:  width,
@@ -1,47 +1,108 @@
 package game
 
 import (
-	"math"
+	"errors"
+	"fmt"
 	"math/rand"
 	"sync"
 	"time"
 
-	"github.com/nsf/termbox-go"
-
+	"github.com/CatWantsMeow/gtetris/leaderboard"
 	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/netplay"
+	"github.com/CatWantsMeow/gtetris/replay"
 )
 
 const (
 	FastGameMultiplier = 10
 
+	FramesPerSecond = 60
+	FrameDelay      = time.Second / FramesPerSecond
+	GravityUnit     = 256
+	GravitySonic    = 20 * GravityUnit
+
 	FieldWidth    = 14
 	FieldHeight   = 22
 	PreviewWidth  = 4
 	PreviewHeight = 2
 	PreviewTop    = 0
 	PreviewLeft   = 1
+	MaxPreviews   = 4
+
+	// Limits of custom field sizes.
+	MinFieldWidth  = 8
+	MaxFieldWidth  = 30
+	MinFieldHeight = 10
+	MaxFieldHeight = 40
+
+	// NoDelay as a custom delay turns the delay of levels off, and
+	// MaxDelay is the longest custom delay in frames.
+	NoDelay  = -1
+	MaxDelay = 120
+)
 
+// States of games, boards and players. Saved games keep them by their
+// names in stateNames, so the values may change.
+const (
 	StateInit = iota
 	StateRunning
+	StateEntryDelay
+	StateLineClear
 	StatePaused
 	StateFinished
 	StateClosed
 	StateExiting
+	StateWaiting
+	StateNameEntry
 )
 
 type Level struct {
 	Name        string
-	Delay       int
+	Gravity     int
+	LockDelay   int
+	EntryDelay  int
+	ClearDelay  int
 	LinePoints  int
 	BlockPoints int
 	TickPoints  int
 	StartsAfter int
 }
 
+type Mode struct {
+	Name   string
+	Levels []*Level
+
+	// Scale is the size of a block cell in field cells. Big mode blocks
+	// are scaled by 2 and move on a half-resolution grid.
+	Scale int
+
+	// Players share a single field which is Players times wider unless
+	// they play versus each other on their own boards.
+	Players int
+	Versus  bool
+}
+
+var (
+	UnknownModeError = errors.New("unknown game mode")
+	FieldSizeError   = fmt.Errorf(
+		"field width must be %d to %d and height %d to %d",
+		MinFieldWidth, MaxFieldWidth, MinFieldHeight, MaxFieldHeight,
+	)
+	FieldScaleError = errors.New("field width and height must be multiples of the block size of this mode")
+	StartLevelError = errors.New("no such start level in this mode")
+	DelayError      = fmt.Errorf("delays must be %d to %d frames", NoDelay, MaxDelay)
+	LayoutError     = fmt.Errorf(
+		"layout must be %s, %s or %s", LayoutAuto, LayoutFull, LayoutCompact,
+	)
+)
+
 var (
 	level1 = Level{
 		Name:        "A",
-		Delay:       250,
+		Gravity:     17,
+		LockDelay:   0,
+		EntryDelay:  0,
+		ClearDelay:  0,
 		LinePoints:  100,
 		BlockPoints: 10,
 		TickPoints:  0,
@@ -49,7 +110,10 @@ var (
 	}
 	level2 = Level{
 		Name:        "B",
-		Delay:       200,
+		Gravity:     21,
+		LockDelay:   0,
+		EntryDelay:  0,
+		ClearDelay:  0,
 		LinePoints:  200,
 		BlockPoints: 20,
 		TickPoints:  1,
@@ -57,7 +121,10 @@ var (
 	}
 	level3 = Level{
 		Name:        "C",
-		Delay:       150,
+		Gravity:     28,
+		LockDelay:   0,
+		EntryDelay:  0,
+		ClearDelay:  0,
 		LinePoints:  300,
 		BlockPoints: 30,
 		TickPoints:  2,
@@ -65,7 +132,10 @@ var (
 	}
 	level4 = Level{
 		Name:        "D",
-		Delay:       100,
+		Gravity:     43,
+		LockDelay:   0,
+		EntryDelay:  0,
+		ClearDelay:  0,
 		LinePoints:  500,
 		BlockPoints: 50,
 		TickPoints:  3,
@@ -73,126 +143,454 @@ var (
 	}
 	level5 = Level{
 		Name:        "E",
-		Delay:       50,
+		Gravity:     85,
+		LockDelay:   0,
+		EntryDelay:  0,
+		ClearDelay:  0,
 		LinePoints:  1000,
 		BlockPoints: 100,
 		TickPoints:  4,
 		StartsAfter: 1500,
 	}
-	levels = []*Level{&level1, &level2, &level3, &level4, &level5}
+
+	masterLevel1 = Level{
+		Name:        "M1",
+		Gravity:     GravitySonic,
+		LockDelay:   30,
+		EntryDelay:  27,
+		ClearDelay:  40,
+		LinePoints:  500,
+		BlockPoints: 50,
+		TickPoints:  0,
+		StartsAfter: 0,
+	}
+	masterLevel2 = Level{
+		Name:        "M2",
+		Gravity:     GravitySonic,
+		LockDelay:   24,
+		EntryDelay:  27,
+		ClearDelay:  25,
+		LinePoints:  1000,
+		BlockPoints: 100,
+		TickPoints:  0,
+		StartsAfter: 180,
+	}
+	masterLevel3 = Level{
+		Name:        "M3",
+		Gravity:     GravitySonic,
+		LockDelay:   18,
+		EntryDelay:  18,
+		ClearDelay:  16,
+		LinePoints:  2000,
+		BlockPoints: 200,
+		TickPoints:  0,
+		StartsAfter: 360,
+	}
+
+	classicMode = Mode{
+		Name:    "classic",
+		Levels:  []*Level{&level1, &level2, &level3, &level4, &level5},
+		Scale:   1,
+		Players: 1,
+	}
+	masterMode = Mode{
+		Name:    "master",
+		Levels:  []*Level{&masterLevel1, &masterLevel2, &masterLevel3},
+		Scale:   1,
+		Players: 1,
+	}
+	bigMode = Mode{
+		Name:    "big",
+		Levels:  []*Level{&level1, &level2, &level3, &level4, &level5},
+		Scale:   2,
+		Players: 1,
+	}
+	coopMode = Mode{
+		Name:    "coop",
+		Levels:  []*Level{&level1, &level2, &level3, &level4, &level5},
+		Scale:   1,
+		Players: 2,
+	}
+	versusMode = Mode{
+		Name:    "versus",
+		Levels:  []*Level{&level1, &level2, &level3, &level4, &level5},
+		Scale:   1,
+		Players: 2,
+		Versus:  true,
+	}
+	modes = []*Mode{&classicMode, &masterMode, &bigMode, &coopMode, &versusMode}
 )
 
+// ModeNames returns names of the game modes.
+func ModeNames() []string {
+	var names []string
+	for _, mode := range modes {
+		names = append(names, mode.Name)
+	}
+	return names
+}
+
+func FindMode(name string) (*Mode, error) {
+	for _, mode := range modes {
+		if mode.Name == name {
+			return mode, nil
+		}
+	}
+	return nil, UnknownModeError
+}
+
+type Options struct {
+	Debug      bool
+	Fast       bool
+	Animations bool
+	Mode       string
+
+	// Conn is a connection to the opponent of an online versus game.
+	Conn *netplay.Conn
+
+	// Spectators receive the board of the local player if set.
+	Spectators *netplay.Broadcaster
+
+	// Terminal to play on, the terminal of the process if not set.
+	Terminal Terminal
+
+	// Lobby is a connection to a battle royale lobby joined as Name.
+	Lobby *netplay.Conn
+	Name  string
+
+	// Bot plays instead of the local player.
+	Bot bool
+
+	// Keys are the key bindings, DefaultKeyMap() if nil.
+	Keys KeyMap
+
+	// Mouse enables control with the mouse.
+	Mouse bool
+
+	// Ghost shows where falling blocks would land and Previews is the
+	// number of next blocks shown, one if zero and at most MaxPreviews.
+	Ghost    bool
+	Previews int
+
+	// DAS is the delay in frames before a held left or right key starts
+	// repeating and ARR is the delay between its repeated moves, both
+	// on top of the key repeat of the terminal.
+	DAS int
+	ARR int
+
+	// Glyphs draws letters of shapes in their cells so they can be
+	// told apart without colors.
+	Glyphs bool
+
+	// Layout is LayoutFull, LayoutCompact or LayoutAuto to draw the
+	// compact layout when the full one doesn't fit and glyphs are off,
+	// the same if empty.
+	Layout string
+
+	// Theme is the name of the color theme, DefaultTheme if empty,
+	// with the colors of shapes in Colors replaced, see parseColors.
+	// ColorMode is the name of the color mode of the terminal, which is
+	// detected if empty or ColorsAuto.
+	Theme     string
+	Colors    string
+	ColorMode string
+
+	// Sound rings the terminal bell on line clears and game over.
+	Sound bool
+
+	// Width and Height are the size of the field of each player,
+	// FieldWidth and FieldHeight if zero. Online games always use the
+	// default size.
+	Width  int
+	Height int
+
+	// StartLevel is the number of the level games start at counting
+	// from one, the first level if zero. Games with a custom field
+	// size or start level don't keep high scores.
+	StartLevel int
+
+	// EntryDelay and ClearDelay are the frames between a block locking
+	// and the next one appearing and the frames lines take to clear,
+	// replacing the ones of levels unless zero. NoDelay turns them off.
+	// Online games always use the delays of levels.
+	EntryDelay int
+	ClearDelay int
+
+	// Seed of random blocks and garbage of every game, a new random
+	// seed for each game if zero.
+	Seed int64
+
+	// Leaderboard is a URL of a leaderboard server finished local games
+	// are submitted to as Name.
+	Leaderboard string
+
+	// ReplayDir is a directory replays of finished local games are
+	// saved to, none are saved if empty.
+	ReplayDir string
+
+	// SaveFile is where local games are saved on exit and resumed from
+	// if Resume is set.
+	SaveFile string
+	Resume   bool
+
+	// HighScores is the high score table file of local single player
+	// games, which don't keep high scores if empty.
+	HighScores string
+
+	// History is the file finished local single player games are
+	// recorded to, none are if empty.
+	History string
+}
+
 type Stats struct {
 	Level   string
 	Score   int
 	Lines   int
 	Blocks  int
 	Elapsed float64
+
+	// Pieces counts blocks by shape name and Clears counts line clears
+	// by the number of lines cleared at once, singles first.
+	Pieces map[string]int
+	Clears [4]int
+
+	Inputs          int
+	GarbageSent     int
+	GarbageReceived int
+}
+
+func (s *Stats) PPS() float64 {
+	if s.Elapsed == 0 {
+		return 0
+	}
+	return float64(s.Blocks) / s.Elapsed
 }
 
 type Game struct {
+	term    Terminal
 	screen  *Screen
-	ctrl 	*Controller
-	stats   *Stats
-	field   *Field
-	preview *Field
+	ctrl    *Controller
+	boards  []*Board
+	players []*Player
+
+	state int
+	mode  *Mode
+	frame int
+	conn  *netplay.Conn
+
+	spectators *netplay.Broadcaster
+
+	// Battle royale lobby state: the id of the local player, its
+	// targeting strategy and boards of the other players.
+	lobby     bool
+	id        int
+	name      string
+	strategy  string
+	opponents []*Board
+
+	bot *Bot
+
+	seed        int64
+	fixedSeed   bool
+	rng         *rand.Rand
+	source      *countingSource
+	recording   *replay.Replay
+	replayDir   string
+	recorded    bool
+	leaderboard *leaderboard.Client
+	submissions sync.WaitGroup
+	playback    *Playback
+
+	// Saved game to resume instead of starting a new one, the file
+	// games are saved to and whether it holds this game.
+	resume   *SavedGame
+	saveFile string
+	saved    bool
+
+	highScores string
+	history    string
+
+	fast    bool
+	debug   bool
+	animate bool
+	mouse   bool
+	sound   bool
+
+	// Lines cleared and whether the game was over when the bell was
+	// last checked.
+	bellLines int
+	bellOver  bool
+
+	// Delays of repeated moves, see Options.
+	das int
+	arr int
+
+	// Colors of the theme and the color mode asked for, which the
+	// terminal may not support.
+	palette   palette
+	colorMode ColorMode
+
+	// Custom rules of the game, see Options.
+	width      int
+	height     int
+	startLevel int
+	entryDelay int
+	clearDelay int
 
-	state     int
-	level     *Level
-	curBlock  *Block
-	nextBlock *Block
+	mu sync.Mutex
+}
 
-	fast  bool
-	debug bool
+func (g *Game) tick() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
-	mu sync.Mutex
+	g.update()
+	g.redraw()
 }
 
-func (g *Game) generateBlock() {
-	left := g.field.Width/2 - 1
-	if g.nextBlock == nil {
-		g.curBlock = NewRandomBlock(left, 0)
-	} else {
-		g.curBlock = g.nextBlock.Copy(left, 0)
+// update advances the game by a single frame.
+func (g *Game) update() {
+	g.frame++
+	if g.bot != nil {
+		g.bot.act()
 	}
-	g.nextBlock = NewRandomBlock(PreviewLeft, PreviewTop)
-	log.Debug("Generated new block.")
+	for _, b := range g.boards {
+		b.tick()
+	}
+	for _, b := range g.boards {
+		if b.finished {
+			g.state = StateFinished
+			log.Info("Changed state to finished.")
+		}
+	}
+	if g.state == StateFinished {
+		g.finishRecording()
+		g.discardSave()
+		g.recordHistory()
+		g.checkHighScore()
+	}
+	if g.frame%SnapshotFrames == 0 || g.state == StateFinished {
+		if g.conn != nil {
+			g.sendSnapshot()
+		}
+		if g.spectators != nil {
+			g.publish()
+		}
+	}
+}
 
-	g.tryChangeLevel()
-	g.preview.Clear(false)
-	g.nextBlock.MustDraw(g.preview, false)
-	g.stats.Score += g.level.BlockPoints
-	g.stats.Blocks++
+func (g *Game) redraw() {
+	for _, b := range g.boards {
+		b.redraw()
+	}
+	if g.playback != nil {
+		g.playback.frame = g.frame
+	}
+	g.ring()
+	g.screen.Draw(g.state)
+}
 
-	g.field.Clear(false)
-	if g.curBlock.Overlaps(g.field) {
-		g.state = StateFinished
-		log.Info("Changed state to finished.")
+// ring rings the bell if lines of local boards were cleared or the game
+// got over since it was last called.
+func (g *Game) ring() {
+	lines := 0
+	for _, b := range g.boards {
+		if !b.remote {
+			lines += b.stats.Lines
+		}
 	}
+	over := g.state == StateFinished || g.state == StateNameEntry
+	if g.sound && (lines > g.bellLines || over && !g.bellOver) {
+		g.term.Bell()
+	}
+	g.bellLines = lines
+	g.bellOver = over
 }
 
-func (g *Game) moveDown() {
+// control runs action on the falling block of player if it can be
+// controlled right now. Actions always happen between two frames, so
+// recording them with the number of the last frame is enough to replay
+// them at the same moment of the game.
+func (g *Game) control(p *Player, action int) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	if g.curBlock != nil {
-		ok := g.curBlock.TryMove(0, 1, g.field)
-		if !ok {
-			log.Debug("Failed to move down.")
-			g.curBlock.MustDraw(g.field, true)
-			g.removeLines()
-			g.generateBlock()
-		} else {
-			log.Debug("Moved down.")
+	if g.state == StateRunning && p.board.state == StateRunning && p.curBlock != nil {
+		if !p.repeat.allow(action, g.frame, g.das, g.arr) {
+			return
 		}
+		p.act(action)
+		g.record(p, action)
 		g.redraw()
 	}
 }
 
-func (g *Game) removeLines() {
-	removed := g.field.RemoveFilledLines()
-	if removed > 0 {
-		g.stats.Score += g.level.LinePoints * int(math.Pow(2, float64(removed)))
-		g.stats.Lines += removed
-	}
-}
+func (g *Game) registerPlayer(p *Player) {
+	g.ctrl.RegisterHandler(p.keys.Rotate, func() {
+		g.control(p, replay.ActionRotate)
+	})
 
-func (g *Game) tryChangeLevel() {
-	for _, level := range levels {
-		elapsed := int(g.stats.Elapsed)
-		if g.fast {
-			elapsed *= FastGameMultiplier
-		}
-		if level.StartsAfter <= elapsed {
-			g.level = level
-		}
-	}
-	log.Info("Changed level to %s.", g.level.Name)
-	g.stats.Level = g.level.Name
+	g.ctrl.RegisterHandler(p.keys.Left, func() {
+		g.control(p, replay.ActionLeft)
+	})
+
+	g.ctrl.RegisterHandler(p.keys.Right, func() {
+		g.control(p, replay.ActionRight)
+	})
+
+	g.ctrl.RegisterHandler(p.keys.Drop, func() {
+		g.control(p, replay.ActionDown)
+	})
 }
 
-func (g *Game) redraw() {
-	g.field.Clear(false)
-	g.curBlock.MustDraw(g.field, false)
-	g.screen.Draw(g.state, g.stats)
+// setColors shows the theme with as many colors as the terminal
+// supports.
+func (g *Game) setColors() {
+	mode := g.term.SetColorMode(g.colorMode)
+	g.screen.theme = newTheme(g.palette, mode)
 }
 
 func (g *Game) init() {
 	g.ctrl.RegisterHandler(EventExit, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.save()
 		g.state = StateExiting
 	})
 
+	g.ctrl.RegisterHandler(EventScores, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.toggleHighScores()
+		g.redraw()
+	})
+
+	g.ctrl.RegisterHandler(EventSave, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.save()
+	})
+
 	g.ctrl.RegisterHandler(EventResize, func() {
 		g.screen.Resize()
 		g.redraw()
 	})
 
 	g.ctrl.RegisterHandler(EventNewGame, func() {
+		if g.lobby {
+			return
+		}
 		log.Info("Restarting game.")
 		g.start()
+		if g.conn != nil {
+			g.conn.Send(&netplay.Message{Type: netplay.MessageRestart})
+		}
 	})
 
 	g.ctrl.RegisterHandler(EventPauseResume, func() {
+		if g.lobby {
+			return
+		}
 		switch g.state {
 		case StatePaused:
 			g.state = StateRunning
@@ -204,116 +602,323 @@ func (g *Game) init() {
 		g.redraw()
 	})
 
-	g.ctrl.RegisterHandler(EventUp, func() {
-		if g.state == StateRunning {
-			g.mu.Lock()
-			defer g.mu.Unlock()
-
-			if g.curBlock != nil {
-				ok := g.curBlock.TryRotate(g.field)
-				if ok {
-					log.Debug("Rotated.")
-				} else {
-					log.Debug("Failed to rotate.")
-				}
-				g.redraw()
-			}
+	if g.lobby {
+		strategies := map[int]string{
+			EventTargetRandom:    netplay.StrategyRandom,
+			EventTargetAttackers: netplay.StrategyAttackers,
+			EventTargetKO:        netplay.StrategyKO,
 		}
-	})
-
-	g.ctrl.RegisterHandler(EventLeft, func() {
-		if g.state == StateRunning {
-			g.mu.Lock()
-			defer g.mu.Unlock()
-
-			if g.curBlock != nil {
-				ok := g.curBlock.TryMove(-1, 0, g.field)
-				if ok {
-					log.Debug("Moved left.")
-				} else {
-					log.Debug("Failed to move left.")
-				}
-				g.redraw()
-			}
+		for event, strategy := range strategies {
+			strategy := strategy
+			g.ctrl.RegisterHandler(event, func() {
+				g.setStrategy(strategy)
+			})
 		}
-	})
-
-	g.ctrl.RegisterHandler(EventRight, func() {
-		if g.state == StateRunning {
-			g.mu.Lock()
-			defer g.mu.Unlock()
-
-			if g.curBlock != nil {
-				ok := g.curBlock.TryMove(1, 0, g.field)
-				if ok {
-					log.Debug("Moved right.")
-				} else {
-					log.Debug("Failed to move right.")
-				}
-				g.redraw()
-			}
-		}
-	})
+	}
 
-	g.ctrl.RegisterHandler(EventDown, func() {
-		if g.state == StateRunning {
-			g.moveDown()
-		}
-	})
+	for _, p := range g.players {
+		g.registerPlayer(p)
+	}
 }
 
 func (g *Game) start() {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.restart()
+}
 
-	g.stats.Elapsed = 0
-	g.stats.Score = 0
-	g.stats.Blocks = 0
-	g.stats.Lines = 0
+func (g *Game) restart() {
+	if !g.fixedSeed {
+		g.seed = time.Now().UnixNano()
+	}
+	g.rng.Seed(g.seed)
+	g.startRecording()
+	g.screen.scores = nil
+	log.Info("Started game with seed %d.", g.seed)
 
-	g.level = levels[0]
 	g.state = StateRunning
-	g.field.Clear(true)
-	g.generateBlock()
+	g.frame = 0
+	for _, b := range g.boards {
+		b.start()
+	}
 }
 
 func (g *Game) Run() {
-	err := termbox.Init()
+	err := g.term.Init()
 	if err != nil {
 		panic(err)
 	}
-    defer termbox.Close()
+	defer g.term.Close()
+	g.setColors()
+	if g.mouse {
+		g.term.SetMouse(true)
+		g.ctrl.SetMouse(g.click)
+	}
 
-	rand.Seed(time.Now().UnixNano())
 	g.init()
-	g.start()
+	if g.resume != nil {
+		g.mu.Lock()
+		g.redraw()
+		g.mu.Unlock()
+	} else {
+		g.start()
+	}
+
+	if g.lobby {
+		g.state = StateWaiting
+	}
 
 	go g.ctrl.Run()
-	for {
+	if g.lobby {
+		g.conn.Send(&netplay.Message{Type: netplay.MessageJoin, Name: g.name})
+		go g.receiveLobby()
+	} else if g.conn != nil {
+		go g.receive()
+	}
+
+	ticker := time.NewTicker(FrameDelay)
+	defer ticker.Stop()
+	for range ticker.C {
 		switch g.state {
 		case StateRunning:
-			g.moveDown()
-			g.stats.Elapsed += float64(g.level.Delay) / 1000
-			g.stats.Score += g.level.TickPoints
+			g.tick()
 		case StateExiting:
 			return
 		}
-		time.Sleep(time.Millisecond * time.Duration(g.level.Delay))
 	}
 }
 
-func Run(debug bool, fast bool) {
-	field := NewField(FieldHeight, FieldWidth)
-	preview := NewField(PreviewHeight, PreviewWidth)
-	g := Game{
-		stats:   &Stats{},
-		field:   field,
-		preview: preview,
-		screen:  NewScreen(field, preview, debug),
-		ctrl:    NewController(),
+// playerKeys returns key sets of players from left to right.
+func playerKeys(players int) []KeySet {
+	if players == 1 {
+		return []KeySet{ArrowKeys}
+	}
+	return []KeySet{WASDKeys, ArrowKeys}
+}
+
+func newGame(opts Options) (*Game, error) {
+	mode, err := FindMode(opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := findPalette(opts.Theme, opts.Colors)
+	if err != nil {
+		return nil, err
+	}
+	colorMode, err := ParseColorMode(opts.ColorMode)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Layout {
+	case "", LayoutAuto, LayoutFull, LayoutCompact:
+	default:
+		return nil, LayoutError
+	}
+
+	width, height := opts.Width, opts.Height
+	if width == 0 {
+		width = FieldWidth
+	}
+	if height == 0 {
+		height = FieldHeight
+	}
+	if width < MinFieldWidth || width > MaxFieldWidth || height < MinFieldHeight || height > MaxFieldHeight {
+		return nil, FieldSizeError
+	}
+	// Blocks of big mode move by their size, so they couldn't fill
+	// the last column or row of a field of another size.
+	if width%mode.Scale != 0 || height%mode.Scale != 0 {
+		return nil, FieldScaleError
+	}
+
+	var boards []*Board
+	var players []*Player
+	keys := playerKeys(mode.Players)
+	previews := opts.Previews
+	if previews < 1 {
+		previews = 1
+	}
+	if previews > MaxPreviews {
+		previews = MaxPreviews
+	}
+	if opts.Lobby != nil {
+		// Battle royale games have a single local board sending
+		// garbage to the lobby, which routes it to the opponents.
+		mode = &classicMode
+		field := NewField(FieldHeight, FieldWidth)
+		board := NewBoard(field, mode, opts.Fast, opts.Animations)
+		board.target = &remoteTarget{conn: opts.Lobby}
+		board.name = opts.Name
+		players = append(players, NewPlayer(board, ArrowKeys, FieldWidth/2-1, previews))
+		boards = append(boards, board)
+	} else if opts.Conn != nil {
+		// Online games are always versus games with a single local
+		// player and the opponent's board updated over the network.
+		mode = &versusMode
+		for i := 0; i < 2; i++ {
+			field := NewField(FieldHeight, FieldWidth)
+			boards = append(boards, NewBoard(field, mode, opts.Fast, opts.Animations))
+		}
+		column := FieldWidth/2 - 1
+		players = append(players, NewPlayer(boards[0], ArrowKeys, column, previews))
+		boards[0].target = &remoteTarget{conn: opts.Conn}
+		boards[1].remote = true
+	} else if mode.Versus {
+		for i := range keys {
+			field := NewField(height, width)
+			board := NewBoard(field, mode, opts.Fast, opts.Animations)
+			column := field.Width/mode.Scale/2 - 1
+			players = append(players, NewPlayer(board, keys[i], column*mode.Scale, previews))
+			boards = append(boards, board)
+		}
+		boards[0].target = boards[1]
+		boards[1].target = boards[0]
+	} else {
+		field := NewField(height, width*mode.Players)
+		board := NewBoard(field, mode, opts.Fast, opts.Animations)
+		for i := range keys {
+			// Players spawn in the middle of their own part of the field.
+			column := field.Width*(2*i+1)/(2*mode.Players)/mode.Scale - 1
+			players = append(players, NewPlayer(board, keys[i], column*mode.Scale, previews))
+		}
+		boards = append(boards, board)
+	}
+
+	startLevel := opts.StartLevel
+	if startLevel == 0 {
+		startLevel = 1
+	}
+	if startLevel < 1 || startLevel > len(mode.Levels) {
+		return nil, StartLevelError
+	}
+	for _, b := range boards {
+		b.startLevel = startLevel - 1
+	}
+
+	for _, delay := range []int{opts.EntryDelay, opts.ClearDelay} {
+		if delay < NoDelay || delay > MaxDelay {
+			return nil, DelayError
+		}
+	}
+	if opts.Conn == nil && opts.Lobby == nil {
+		for _, b := range boards {
+			b.entryDelay = opts.EntryDelay
+			b.clearDelay = opts.ClearDelay
+		}
+	}
+
+	term := opts.Terminal
+	if term == nil {
+		term = NewTermboxTerminal()
+	}
+
+	conn := opts.Conn
+	if opts.Lobby != nil {
+		conn = opts.Lobby
+	}
+
+	g := &Game{
+		term:    term,
+		boards:  boards,
+		players: players,
+		screen:  NewScreen(term, boards, opts.Debug),
+		ctrl:    NewController(term),
 		state:   StateInit,
-		fast:    fast,
-		debug:   debug,
+		mode:    mode,
+		conn:    conn,
+		fast:    opts.Fast,
+		debug:   opts.Debug,
+		animate: opts.Animations,
+		mouse:   opts.Mouse,
+		sound:   opts.Sound,
+		das:     opts.DAS,
+		arr:     opts.ARR,
+
+		width:      opts.Width,
+		height:     opts.Height,
+		startLevel: opts.StartLevel,
+		entryDelay: opts.EntryDelay,
+		clearDelay: opts.ClearDelay,
+
+		spectators: opts.Spectators,
+
+		lobby:    opts.Lobby != nil,
+		name:     opts.Name,
+		strategy: netplay.StrategyRandom,
+
+		seed:      opts.Seed,
+		fixedSeed: opts.Seed != 0,
+		source:    newCountingSource(opts.Seed),
+		replayDir: opts.ReplayDir,
+		saveFile:  opts.SaveFile,
+	}
+	g.rng = rand.New(g.source)
+	for _, b := range boards {
+		b.rng = g.rng
+	}
+	// Only local games are deterministic and can be recorded, and only
+	// their scores are comparable between players.
+	g.recorded = opts.Conn == nil && opts.Lobby == nil && !opts.Bot
+	custom := g.width != 0 || g.height != 0 || g.startLevel > 1 ||
+		g.entryDelay != 0 || g.clearDelay != 0
+	if opts.Leaderboard != "" && g.recorded && !mode.Versus && !custom {
+		g.leaderboard = leaderboard.NewClient(opts.Leaderboard)
+	}
+	if g.recorded && !mode.Versus {
+		if !custom {
+			g.highScores = opts.HighScores
+		}
+		g.history = opts.History
+	}
+	if opts.Keys != nil {
+		g.ctrl.SetKeyMap(opts.Keys)
+		g.screen.keys = opts.Keys
+	}
+	g.screen.theme = newTheme(palette, ColorsBasic)
+	g.palette = palette
+	g.colorMode = colorMode
+	g.screen.ghost = opts.Ghost
+	g.screen.glyphs = opts.Glyphs
+	g.screen.layout = opts.Layout
+	g.screen.battle = g.lobby
+	g.screen.strategy = g.strategy
+	if opts.Bot {
+		g.bot = NewBot(players[0])
+	}
+	return g, nil
+}
+
+func Run(opts Options) error {
+	var saved *SavedGame
+	if opts.Resume && opts.Conn == nil && opts.Lobby == nil && !opts.Bot {
+		var err error
+		saved, err = LoadGame(opts.SaveFile)
+		if err != nil {
+			return err
+		}
+		opts.Mode = saved.Mode
+		opts.Fast = saved.Fast
+		opts.Animations = saved.Animations
+		opts.Previews = saved.Previews
+		opts.Width = saved.Width
+		opts.Height = saved.Height
+		opts.StartLevel = saved.StartLevel
+		opts.EntryDelay = saved.EntryDelay
+		opts.ClearDelay = saved.ClearDelay
+	}
+
+	g, err := newGame(opts)
+	if err != nil {
+		return err
+	}
+	if saved != nil {
+		err = g.restore(saved)
+		if err != nil {
+			return err
+		}
+		g.resume = saved
 	}
 	g.Run()
+	g.waitSubmissions()
+	return nil
 }
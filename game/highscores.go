@@ -0,0 +1,134 @@
+package game
+
+import (
+	"time"
+
+	"github.com/nsf/termbox-go"
+
+	"github.com/CatWantsMeow/gtetris/highscores"
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+// ScoresView is the high score table of a mode shown over the board,
+// or the prompt for a name while a new high score is being entered.
+type ScoresView struct {
+	Mode    string
+	Entries []*highscores.Entry
+	Rank    int
+	Name    string
+	Typing  bool
+}
+
+func (g *Game) loadHighScores() *highscores.Table {
+	table, err := highscores.Load(g.highScores)
+	if err != nil {
+		log.Error("Failed to load high scores: %s.", err)
+		return nil
+	}
+	return table
+}
+
+// checkHighScore asks for a name when the finished game made it into
+// the high score table.
+func (g *Game) checkHighScore() {
+	if g.highScores == "" {
+		return
+	}
+	table := g.loadHighScores()
+	if table == nil {
+		return
+	}
+
+	stats := g.boards[0].stats
+	rank := table.Rank(g.mode.Name, stats.Score)
+	if rank == 0 {
+		return
+	}
+
+	name := []rune(g.name)
+	if len(name) > highscores.MaxNameLength {
+		name = name[:highscores.MaxNameLength]
+	}
+	g.state = StateNameEntry
+	g.screen.scores = &ScoresView{Mode: g.mode.Name, Rank: rank, Name: string(name), Typing: true}
+	g.ctrl.SetInput(g.typeName)
+	log.Info("New high score at place %d.", rank)
+}
+
+func (g *Game) typeName(e termbox.Event) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	view := g.screen.scores
+	name := []rune(view.Name)
+	switch {
+	case e.Key == termbox.KeyEnter && len(name) > 0:
+		g.addHighScore()
+	case e.Key == termbox.KeyEsc:
+		g.ctrl.SetInput(nil)
+		g.screen.scores = nil
+		g.state = StateFinished
+	case e.Key == termbox.KeyBackspace || e.Key == termbox.KeyBackspace2:
+		if len(name) > 0 {
+			view.Name = string(name[:len(name)-1])
+		}
+	case e.Key == termbox.KeySpace && len(name) < highscores.MaxNameLength:
+		view.Name += " "
+	case e.Ch > ' ' && len(name) < highscores.MaxNameLength:
+		view.Name += string(e.Ch)
+	}
+	g.redraw()
+}
+
+// addHighScore adds the finished game to the table reloaded in case
+// another game updated it meanwhile, and shows the table.
+func (g *Game) addHighScore() {
+	g.ctrl.SetInput(nil)
+	g.state = StateFinished
+
+	view := g.screen.scores
+	table := g.loadHighScores()
+	if table == nil {
+		g.screen.scores = nil
+		return
+	}
+
+	stats := g.boards[0].stats
+	rank := table.Add(g.mode.Name, &highscores.Entry{
+		Name:     view.Name,
+		Score:    stats.Score,
+		Lines:    stats.Lines,
+		Level:    stats.Level,
+		Duration: stats.Elapsed,
+		Date:     time.Now(),
+	})
+	err := table.Save(g.highScores)
+	if err != nil {
+		log.Error("Failed to save high scores: %s.", err)
+	}
+
+	view.Typing = false
+	view.Rank = rank
+	view.Entries = table.Top(g.mode.Name)
+}
+
+// toggleHighScores shows or hides the high score table, pausing the
+// running game.
+func (g *Game) toggleHighScores() {
+	if g.highScores == "" || g.state == StateNameEntry {
+		return
+	}
+	if g.screen.scores != nil {
+		g.screen.scores = nil
+		return
+	}
+
+	table := g.loadHighScores()
+	if table == nil {
+		return
+	}
+	if g.state == StateRunning {
+		g.state = StatePaused
+	}
+	g.screen.scores = &ScoresView{Mode: g.mode.Name, Entries: table.Top(g.mode.Name)}
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/history"
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+// recordHistory adds the finished game to the history of games.
+func (g *Game) recordHistory() {
+	if g.history == "" {
+		return
+	}
+
+	stats := g.boards[0].stats
+	pieces := make(map[string]int)
+	for shape, n := range stats.Pieces {
+		pieces[shape] = n
+	}
+	entry := &history.Game{
+		Date:            time.Now(),
+		Mode:            g.mode.Name,
+		Duration:        stats.Elapsed,
+		Score:           stats.Score,
+		Lines:           stats.Lines,
+		Blocks:          stats.Blocks,
+		Level:           stats.Level,
+		Pieces:          pieces,
+		Clears:          stats.Clears,
+		Inputs:          stats.Inputs,
+		GarbageSent:     stats.GarbageSent,
+		GarbageReceived: stats.GarbageReceived,
+	}
+
+	path := g.history
+	go func() {
+		err := history.Append(path, entry)
+		if err != nil {
+			log.Error("Failed to record game history: %s.", err)
+		}
+	}()
+}
@@ -0,0 +1,323 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	DefaultKeyPreset = "arrows"
+
+	// ExitKey always closes the game whatever it is bound to so a
+	// broken key map can't lock anyone in.
+	ExitKey = "ctrl-c"
+)
+
+var (
+	UnknownPresetError = errors.New("unknown key preset")
+	ReservedKeyError   = fmt.Errorf("%s is reserved for closing the game", ExitKey)
+)
+
+// Action is something keys can be bound to.
+type Action struct {
+	Name  string
+	Event int
+	Label string
+}
+
+// Actions are listed in the order they are shown in the key settings.
+var Actions = []Action{
+	{"left", EventLeft, "Move left"},
+	{"right", EventRight, "Move right"},
+	{"drop", EventDown, "Speed up"},
+	{"rotate", EventUp, "Rotate"},
+	{"left2", EventLeft2, "Left player: move left"},
+	{"right2", EventRight2, "Left player: move right"},
+	{"drop2", EventDown2, "Left player: speed up"},
+	{"rotate2", EventUp2, "Left player: rotate"},
+	{"exit", EventExit, "Close game"},
+	{"pause", EventPauseResume, "Pause/resume"},
+	{"new-game", EventNewGame, "Restart"},
+	{"save", EventSave, "Save game"},
+	{"scores", EventScores, "High scores"},
+	{"target-random", EventTargetRandom, "Battle: target random"},
+	{"target-attackers", EventTargetAttackers, "Battle: target attackers"},
+	{"target-ko", EventTargetKO, "Battle: target KO"},
+	{"speed-up", EventSpeedUp, "Replay: speed up"},
+	{"slow-down", EventSlowDown, "Replay: slow down"},
+	{"step", EventStep, "Replay: step frame"},
+	{"next-piece", EventNextPiece, "Replay: next piece"},
+	{"prev-piece", EventPrevPiece, "Replay: previous piece"},
+}
+
+// KeyPresets are names of the presets in the order they are cycled
+// through in the key settings.
+var KeyPresets = []string{"arrows", "wasd", "vim"}
+
+var (
+	arrowKeys = map[string][]string{
+		"left":   {"left"},
+		"right":  {"right"},
+		"drop":   {"down"},
+		"rotate": {"up"},
+	}
+	wasdKeys = map[string][]string{
+		"left":   {"a"},
+		"right":  {"d"},
+		"drop":   {"s"},
+		"rotate": {"w"},
+	}
+	vimKeys = map[string][]string{
+		"left":   {"h"},
+		"right":  {"l"},
+		"drop":   {"j"},
+		"rotate": {"k"},
+	}
+	// commonKeys are bound in every preset, on keys none of the ones
+	// above use.
+	commonKeys = map[string][]string{
+		"exit":             {"esc", "ctrl-d"},
+		"pause":            {"p"},
+		"new-game":         {"n"},
+		"save":             {"ctrl-s"},
+		"scores":           {"tab"},
+		"target-random":    {"1"},
+		"target-attackers": {"2"},
+		"target-ko":        {"3"},
+		"speed-up":         {"+", "="},
+		"slow-down":        {"-"},
+		"step":             {"."},
+		"next-piece":       {"]"},
+		"prev-piece":       {"["},
+	}
+)
+
+var keyNames = map[termbox.Key]string{
+	termbox.KeyArrowUp:    "up",
+	termbox.KeyArrowDown:  "down",
+	termbox.KeyArrowLeft:  "left",
+	termbox.KeyArrowRight: "right",
+	termbox.KeyEsc:        "esc",
+	termbox.KeyEnter:      "enter",
+	termbox.KeySpace:      "space",
+	termbox.KeyTab:        "tab",
+	termbox.KeyBackspace:  "backspace",
+	termbox.KeyBackspace2: "backspace",
+	termbox.KeyInsert:     "insert",
+	termbox.KeyDelete:     "delete",
+	termbox.KeyHome:       "home",
+	termbox.KeyEnd:        "end",
+	termbox.KeyPgup:       "pgup",
+	termbox.KeyPgdn:       "pgdn",
+	termbox.KeyF1:         "f1",
+	termbox.KeyF2:         "f2",
+	termbox.KeyF3:         "f3",
+	termbox.KeyF4:         "f4",
+	termbox.KeyF5:         "f5",
+	termbox.KeyF6:         "f6",
+	termbox.KeyF7:         "f7",
+	termbox.KeyF8:         "f8",
+	termbox.KeyF9:         "f9",
+	termbox.KeyF10:        "f10",
+	termbox.KeyF11:        "f11",
+	termbox.KeyF12:        "f12",
+}
+
+// Arrows are shown as symbols in help prompts.
+var keyLabels = map[string]string{
+	"up":    "↑",
+	"down":  "↓",
+	"left":  "←",
+	"right": "→",
+}
+
+// KeyName returns the name of the key pressed in e: the character typed,
+// a name such as up, esc or f1, or ctrl- followed by a letter. It is
+// empty for events of other types and unknown keys.
+func KeyName(e termbox.Event) string {
+	if e.Type != termbox.EventKey {
+		return ""
+	}
+	if e.Ch != 0 {
+		return string(e.Ch)
+	}
+	if name, ok := keyNames[e.Key]; ok {
+		return name
+	}
+	if e.Key >= termbox.KeyCtrlA && e.Key <= termbox.KeyCtrlZ {
+		return "ctrl-" + string(rune('a'+e.Key-termbox.KeyCtrlA))
+	}
+	return ""
+}
+
+func validKey(name string) bool {
+	if utf8.RuneCountInString(name) == 1 {
+		return name != " "
+	}
+	for _, n := range keyNames {
+		if n == name {
+			return true
+		}
+	}
+	return len(name) == len("ctrl-a") && strings.HasPrefix(name, "ctrl-") &&
+		name[5] >= 'a' && name[5] <= 'z'
+}
+
+func findAction(name string) (Action, bool) {
+	for _, a := range Actions {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+// KeyMap binds actions by name to one or more keys by name.
+type KeyMap map[string][]string
+
+// KeyPreset returns a new key map of the named preset. Every preset has
+// the other player of two player modes on the keys left free by the
+// first one.
+func KeyPreset(name string) (KeyMap, error) {
+	var first, second map[string][]string
+	switch name {
+	case "arrows":
+		first, second = arrowKeys, wasdKeys
+	case "wasd":
+		first, second = wasdKeys, arrowKeys
+	case "vim":
+		first, second = vimKeys, wasdKeys
+	default:
+		return nil, UnknownPresetError
+	}
+
+	km := make(KeyMap)
+	for action, keys := range commonKeys {
+		km[action] = append([]string{}, keys...)
+	}
+	for action, keys := range first {
+		km[action] = append([]string{}, keys...)
+	}
+	for action, keys := range second {
+		km[action+"2"] = append([]string{}, keys...)
+	}
+	return km, nil
+}
+
+// DefaultKeyMap returns a new key map of the default preset.
+func DefaultKeyMap() KeyMap {
+	km, _ := KeyPreset(DefaultKeyPreset)
+	return km
+}
+
+// LoadKeyMap returns the key map of preset, the default one if empty,
+// with the bindings of actions in keys replaced. Conflicting bindings
+// aren't errors here and are left to Conflicts.
+func LoadKeyMap(preset string, keys map[string][]string) (KeyMap, error) {
+	if preset == "" {
+		preset = DefaultKeyPreset
+	}
+	km, err := KeyPreset(preset)
+	if err != nil {
+		return nil, err
+	}
+	for action, names := range keys {
+		if _, ok := findAction(action); !ok {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		for _, name := range names {
+			if name == ExitKey {
+				return nil, ReservedKeyError
+			}
+			if !validKey(name) {
+				return nil, fmt.Errorf("unknown key %q bound to %s", name, action)
+			}
+		}
+		km[action] = append([]string{}, names...)
+	}
+	return km, nil
+}
+
+// Bind adds key to the keys of action.
+func (km KeyMap) Bind(action, key string) {
+	for _, k := range km[action] {
+		if k == key {
+			return
+		}
+	}
+	km[action] = append(km[action], key)
+}
+
+// Conflicts returns actions sharing each key bound to more than one
+// action.
+func (km KeyMap) Conflicts() map[string][]string {
+	actions := make(map[string][]string)
+	for _, a := range Actions {
+		for _, key := range km[a.Name] {
+			actions[key] = append(actions[key], a.Name)
+		}
+	}
+	for key, names := range actions {
+		if len(names) < 2 {
+			delete(actions, key)
+		}
+	}
+	return actions
+}
+
+// ConflictError describes conflicts of km or returns nil if there are
+// none.
+func (km KeyMap) ConflictError() error {
+	conflicts := km.Conflicts()
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for key, actions := range conflicts {
+		lines = append(lines, fmt.Sprintf("%s is bound to %s", key, strings.Join(actions, ", ")))
+	}
+	sort.Strings(lines)
+	return errors.New("conflicting key bindings: " + strings.Join(lines, "; "))
+}
+
+// events returns events of keys. A key bound to several actions sends
+// the event of the first one in Actions.
+func (km KeyMap) events() map[string]int {
+	events := make(map[string]int)
+	for i := len(Actions) - 1; i >= 0; i-- {
+		for _, key := range km[Actions[i].Name] {
+			events[key] = Actions[i].Event
+		}
+	}
+	return events
+}
+
+// Label returns the first key of action as shown in help prompts.
+func (km KeyMap) Label(action string) string {
+	keys := km[action]
+	if len(keys) == 0 {
+		return ""
+	}
+	if label, ok := keyLabels[keys[0]]; ok {
+		return label
+	}
+	return keys[0]
+}
+
+// Keys returns all keys of action separated by spaces.
+func (km KeyMap) Keys(action string) string {
+	var labels []string
+	for _, key := range km[action] {
+		if label, ok := keyLabels[key]; ok {
+			key = label
+		}
+		labels = append(labels, key)
+	}
+	return strings.Join(labels, " ")
+}
@@ -0,0 +1,207 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	KeySettingsTitle    = "Key bindings"
+	KeySettingsPreset   = "Preset: %s"
+	KeySettingsRow      = "%-26s %s"
+	KeySettingsHelp     = "Add key: enter  Clear: backspace  Preset: tab  Save: ctrl-s  Back: esc"
+	KeySettingsCapture  = "Press a key for %s, ctrl-c to cancel"
+	KeySettingsConflict = "Keys in red are bound to several actions"
+	KeySettingsColor    = termbox.ColorRed
+
+	// Lines of the key settings other than rows of actions and the
+	// number of them above the rows.
+	keySettingsMargin = 8
+	keySettingsRows   = 3
+)
+
+// keySettings is the state of the key settings screen.
+type keySettings struct {
+	keys     KeyMap
+	preset   string
+	selected int
+	capture  bool
+	status   string
+}
+
+// draw draws the key settings and returns the line of the first row
+// and the index of its action.
+func (k *keySettings) draw(term Terminal) (int, int) {
+	conflicts := k.keys.Conflicts()
+	conflicting := func(action string) bool {
+		for _, key := range k.keys[action] {
+			if _, ok := conflicts[key]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Rows scroll to keep the selected one visible on short terminals.
+	_, h := term.Size()
+	rows := h - keySettingsMargin
+	if rows < 1 {
+		rows = 1
+	}
+	first := 0
+	if k.selected >= rows {
+		first = k.selected - rows + 1
+	}
+	last := first + rows
+	if last > len(Actions) {
+		last = len(Actions)
+	}
+
+	lines := []string{KeySettingsTitle, fmt.Sprintf(KeySettingsPreset, k.preset), ""}
+	colors := map[int]termbox.Attribute{0: MenuTitleColor}
+	for i := first; i < last; i++ {
+		a := Actions[i]
+		prefix := MenuPrefix
+		switch {
+		case i == k.selected:
+			prefix = MenuSelectedPrefix
+			colors[len(lines)] = MenuSelectedColor
+		case conflicting(a.Name):
+			colors[len(lines)] = KeySettingsColor
+		}
+		lines = append(lines, prefix+fmt.Sprintf(KeySettingsRow, a.Label, k.keys.Keys(a.Name)))
+	}
+
+	status := k.status
+	switch {
+	case k.capture:
+		status = fmt.Sprintf(KeySettingsCapture, Actions[k.selected].Label)
+	case status == "" && len(conflicts) > 0:
+		status = KeySettingsConflict
+	}
+	lines = append(lines, "", status, KeySettingsHelp)
+	_, top := drawCentered(term, lines, colors)
+	return top + keySettingsRows, first
+}
+
+// click selects the row clicked in e and starts waiting for a key if
+// it is already selected. The wheel scrolls the rows.
+func (k *keySettings) click(e termbox.Event, top, first int) {
+	switch e.Key {
+	case termbox.MouseLeft:
+		i := first + e.MouseY - top
+		if e.MouseY < top || i >= len(Actions) || e.Mod&termbox.ModMotion != 0 {
+			return
+		}
+		k.capture = i == k.selected
+		k.selected = i
+	case termbox.MouseWheelUp:
+		k.selected = (k.selected + len(Actions) - 1) % len(Actions)
+	case termbox.MouseWheelDown:
+		k.selected = (k.selected + 1) % len(Actions)
+	}
+}
+
+// bind binds the key pressed in e to the selected action.
+func (k *keySettings) bind(e termbox.Event) {
+	k.capture = false
+	key := KeyName(e)
+	switch key {
+	case "":
+		k.status = "Unknown key"
+	case ExitKey:
+		k.status = ""
+	default:
+		k.keys.Bind(Actions[k.selected].Name, key)
+		k.status = ""
+	}
+}
+
+func (k *keySettings) nextPreset() {
+	next := 0
+	for i, name := range KeyPresets {
+		if name == k.preset {
+			next = (i + 1) % len(KeyPresets)
+		}
+	}
+	k.preset = KeyPresets[next]
+	k.keys, _ = KeyPreset(k.preset)
+	k.status = ""
+}
+
+// EditKeys shows the key settings on an initialized term starting with
+// km of preset. It returns the edited key map and the preset it is
+// based on, or a nil map if editing is canceled.
+func EditKeys(term Terminal, km KeyMap, preset string) (KeyMap, string) {
+	if preset == "" {
+		preset = DefaultKeyPreset
+	}
+	k := &keySettings{keys: make(KeyMap), preset: preset}
+	for action, keys := range km {
+		k.keys[action] = append([]string{}, keys...)
+	}
+
+	for {
+		top, first := k.draw(term)
+		e := term.PollEvent()
+		switch e.Type {
+		case termbox.EventError:
+			return nil, ""
+		case termbox.EventMouse:
+			if !k.capture {
+				k.status = ""
+				k.click(e, top, first)
+			}
+			continue
+		case termbox.EventKey:
+		default:
+			continue
+		}
+
+		if k.capture {
+			k.bind(e)
+			continue
+		}
+
+		k.status = ""
+		switch {
+		case e.Key == termbox.KeyArrowUp || e.Ch == 'k':
+			k.selected = (k.selected + len(Actions) - 1) % len(Actions)
+		case e.Key == termbox.KeyArrowDown || e.Ch == 'j':
+			k.selected = (k.selected + 1) % len(Actions)
+		case e.Key == termbox.KeyEnter || e.Key == termbox.KeySpace:
+			k.capture = true
+		case e.Key == termbox.KeyBackspace || e.Key == termbox.KeyBackspace2 || e.Key == termbox.KeyDelete:
+			// An empty list rather than no entry, so the cleared binding
+			// is saved instead of falling back to the preset's one.
+			k.keys[Actions[k.selected].Name] = []string{}
+		case e.Key == termbox.KeyTab:
+			k.nextPreset()
+		case e.Key == termbox.KeyCtrlS:
+			if len(k.keys.Conflicts()) > 0 {
+				k.status = "Fix keys in red before saving"
+				continue
+			}
+			return k.keys, k.preset
+		case e.Key == termbox.KeyEsc || e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD:
+			return nil, ""
+		}
+	}
+}
+
+// KeySettings lets the player edit km of preset on the terminal of the
+// process, with the mouse too if mouse is set. It returns a nil map if
+// nothing was changed.
+func KeySettings(km KeyMap, preset string, mouse bool) (KeyMap, string, error) {
+	term := NewTermboxTerminal()
+	err := term.Init()
+	if err != nil {
+		return nil, "", err
+	}
+	defer term.Close()
+	term.SetMouse(mouse)
+
+	km, preset = EditKeys(term, km, preset)
+	return km, preset, nil
+}
@@ -0,0 +1,220 @@
+package game
+
+import (
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	MenuSelectedPrefix = "> "
+	MenuPrefix         = "  "
+	MenuTitleColor     = termbox.ColorYellow
+	MenuSelectedColor  = termbox.ColorGreen
+	MenuHelp           = "Select: arrows, enter   Back: esc"
+	PromptHelp         = "Done: enter   Cancel: esc"
+	PromptErrorColor   = termbox.ColorRed
+	MaxPromptLength    = 32
+)
+
+// Menu is a list of items chosen from with arrows and enter, drawn in
+// the middle of the terminal below the header. Help replaces MenuHelp
+// if set.
+type Menu struct {
+	Title    string
+	Items    []string
+	Selected int
+	Help     string
+}
+
+// drawCentered draws lines in the middle of term and returns the
+// position of the first one.
+func drawCentered(term Terminal, lines []string, colors map[int]termbox.Attribute) (int, int) {
+	err := term.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	if err != nil {
+		panic(err)
+	}
+
+	w, h := term.Size()
+	width := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	left := (w - width) / 2
+	top := (h - len(lines)) / 2
+	if left < 0 {
+		left = 0
+	}
+	if top < 0 {
+		top = 0
+	}
+
+	for i, line := range lines {
+		color, ok := colors[i]
+		if !ok {
+			color = termbox.ColorDefault
+		}
+		for j, ch := range []rune(line) {
+			term.SetCell(left+j, top+i, ch, color, BackgroundColor)
+		}
+	}
+
+	err = term.Flush()
+	if err != nil {
+		panic(err)
+	}
+	return left, top
+}
+
+// draw draws the menu and returns the line of its first item.
+func (m *Menu) draw(term Terminal) int {
+	lines := strings.Split(strings.TrimPrefix(Header, "\n"), "\n")
+	colors := map[int]termbox.Attribute{}
+	lines = append(lines, m.Title, "")
+	colors[len(lines)-2] = MenuTitleColor
+	first := len(lines)
+	for i, item := range m.Items {
+		prefix := MenuPrefix
+		if i == m.Selected {
+			prefix = MenuSelectedPrefix
+			colors[len(lines)] = MenuSelectedColor
+		}
+		lines = append(lines, prefix+item)
+	}
+	help := m.Help
+	if help == "" {
+		help = MenuHelp
+	}
+	lines = append(lines, "", help)
+	_, top := drawCentered(term, lines, colors)
+	return top + first
+}
+
+// Choose shows the menu on an initialized term until an item is chosen
+// with keys or a click and returns its index, or -1 if the menu is
+// closed.
+func (m *Menu) Choose(term Terminal) int {
+	for {
+		first := m.draw(term)
+		e := term.PollEvent()
+		switch e.Type {
+		case termbox.EventError:
+			return -1
+		case termbox.EventMouse:
+			if i, ok := m.click(e, first); ok {
+				return i
+			}
+			continue
+		case termbox.EventKey:
+		default:
+			continue
+		}
+
+		switch {
+		case e.Key == termbox.KeyArrowUp || e.Ch == 'k':
+			m.Selected = (m.Selected + len(m.Items) - 1) % len(m.Items)
+		case e.Key == termbox.KeyArrowDown || e.Ch == 'j':
+			m.Selected = (m.Selected + 1) % len(m.Items)
+		case e.Key == termbox.KeyEnter || e.Key == termbox.KeySpace:
+			return m.Selected
+		case e.Key == termbox.KeyEsc || e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD:
+			return -1
+		}
+	}
+}
+
+// click selects the item clicked in e or scrolls the items with the
+// wheel. It returns the item if it is chosen.
+func (m *Menu) click(e termbox.Event, first int) (int, bool) {
+	switch e.Key {
+	case termbox.MouseLeft:
+		i := e.MouseY - first
+		if i >= 0 && i < len(m.Items) && e.Mod&termbox.ModMotion == 0 {
+			m.Selected = i
+			return i, true
+		}
+	case termbox.MouseWheelUp:
+		m.Selected = (m.Selected + len(m.Items) - 1) % len(m.Items)
+	case termbox.MouseWheelDown:
+		m.Selected = (m.Selected + 1) % len(m.Items)
+	}
+	return 0, false
+}
+
+// Prompt asks for a line of text on an initialized term and returns it,
+// or an empty string if it is canceled.
+func Prompt(term Terminal, title string) string {
+	return PromptValid(term, title, nil)
+}
+
+// PromptValid is Prompt accepting only text valid returns no error for,
+// showing the error otherwise. Any text is accepted if valid is nil.
+func PromptValid(term Terminal, title string, valid func(text string) error) string {
+	var text []rune
+	status := ""
+	for {
+		lines := []string{title, "", string(text) + "_", status, PromptHelp}
+		drawCentered(term, lines, map[int]termbox.Attribute{0: MenuTitleColor, 3: PromptErrorColor})
+
+		e := term.PollEvent()
+		if e.Type == termbox.EventError {
+			return ""
+		}
+		if e.Type != termbox.EventKey {
+			continue
+		}
+		status = ""
+		switch {
+		case e.Key == termbox.KeyEnter:
+			str := strings.TrimSpace(string(text))
+			if valid != nil && str != "" {
+				if err := valid(str); err != nil {
+					status = err.Error()
+					continue
+				}
+			}
+			return str
+		case e.Key == termbox.KeyEsc || e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD:
+			return ""
+		case e.Key == termbox.KeyBackspace || e.Key == termbox.KeyBackspace2:
+			if len(text) > 0 {
+				text = text[:len(text)-1]
+			}
+		case e.Key == termbox.KeySpace && len(text) < MaxPromptLength:
+			text = append(text, ' ')
+		case e.Ch > ' ' && len(text) < MaxPromptLength:
+			text = append(text, e.Ch)
+		}
+	}
+}
+
+// ChooseProfile lets the player pick one of profiles or name a new one
+// valid returns no error for, with the mouse too if mouse is set. It
+// returns an empty name if nothing was chosen.
+func ChooseProfile(profiles []string, valid func(name string) error, mouse bool) (string, error) {
+	term := NewTermboxTerminal()
+	err := term.Init()
+	if err != nil {
+		return "", err
+	}
+	defer term.Close()
+	term.SetMouse(mouse)
+
+	items := append(append([]string{}, profiles...), "New profile...")
+	menu := &Menu{Title: "Who is playing?", Items: items}
+	for {
+		i := menu.Choose(term)
+		switch {
+		case i < 0:
+			return "", nil
+		case i < len(profiles):
+			return profiles[i], nil
+		}
+		name := PromptValid(term, "Name of the new profile:", valid)
+		if name != "" {
+			return name, nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"github.com/nsf/termbox-go"
+
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+// mousePlayer returns the player controlled with the mouse, the one
+// using the arrow keys in two player modes.
+func (g *Game) mousePlayer() *Player {
+	for _, p := range g.players {
+		if p.keys == ArrowKeys {
+			return p
+		}
+	}
+	return nil
+}
+
+// press sends the event of the button at the position of e, if any.
+func (g *Game) press(e termbox.Event) bool {
+	if e.Key != termbox.MouseLeft || e.Mod&termbox.ModMotion != 0 {
+		return false
+	}
+
+	g.mu.Lock()
+	action := g.screen.ButtonAt(e.MouseX, e.MouseY)
+	g.mu.Unlock()
+
+	a, ok := findAction(action)
+	if ok {
+		g.ctrl.handle(a.Event)
+	}
+	return ok
+}
+
+// click controls the game with the mouse. Clicking or dragging over a
+// column of the board moves the falling block there, right clicks and
+// the wheel rotate it and help lines work as buttons.
+func (g *Game) click(e termbox.Event) {
+	if g.press(e) {
+		return
+	}
+
+	p := g.mousePlayer()
+	if p == nil {
+		return
+	}
+	switch e.Key {
+	case termbox.MouseLeft:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		b, column, ok := g.screen.ColumnAt(e.MouseX, e.MouseY)
+		if ok && b == p.board {
+			g.moveTo(p, column)
+		}
+	case termbox.MouseRight, termbox.MouseWheelUp, termbox.MouseWheelDown:
+		g.control(p, replay.ActionRotate)
+	}
+}
+
+// moveTo moves the falling block of p left or right until it is
+// centered over column or can't move any further. Every step is an
+// action of its own so the move is replayed exactly.
+func (g *Game) moveTo(p *Player, column int) {
+	if g.state != StateRunning || p.board.state != StateRunning {
+		return
+	}
+
+	for i := 0; i < p.board.field.Width && p.curBlock != nil; i++ {
+		cells := p.curBlock.Cells()
+		left, right := cells[0].X, cells[0].X
+		for _, c := range cells {
+			if c.X < left {
+				left = c.X
+			}
+			if c.X > right {
+				right = c.X
+			}
+		}
+
+		// Big blocks move by a whole block cell at once.
+		delta := column - (left+right)/2
+		if delta > -p.curBlock.scale && delta < p.curBlock.scale {
+			break
+		}
+		action := replay.ActionRight
+		if delta < 0 {
+			action = replay.ActionLeft
+		}
+
+		x := p.curBlock.x
+		p.act(action)
+		g.record(p, action)
+		if p.curBlock.x == x {
+			break
+		}
+	}
+	g.redraw()
+}
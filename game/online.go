@@ -0,0 +1,154 @@
+package game
+
+import (
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/netplay"
+)
+
+const (
+	// Boards are sent to the opponent every SnapshotFrames frames.
+	SnapshotFrames = 6
+)
+
+// Target receives garbage lines sent by a board.
+type Target interface {
+	Receive(lines int)
+}
+
+// remoteTarget sends garbage lines to the opponent over the network.
+type remoteTarget struct {
+	conn *netplay.Conn
+}
+
+func (t *remoteTarget) Receive(lines int) {
+	t.conn.Send(&netplay.Message{Type: netplay.MessageGarbage, Lines: lines})
+}
+
+func (b *Board) Snapshot() *netplay.Board {
+	f := b.field
+	s := &netplay.Board{
+		Width:      f.Width,
+		Height:     f.Height,
+		Cells:      make([]uint16, 0, f.Width*f.Height),
+		Level:      b.stats.Level,
+		Score:      b.stats.Score,
+		Lines:      b.stats.Lines,
+		BlockCount: b.stats.Blocks,
+		Elapsed:    b.stats.Elapsed,
+		Pending:    b.pending,
+		Finished:   b.finished,
+	}
+	for i := 0; i < f.Height; i++ {
+		for j := 0; j < f.Width; j++ {
+			color := f.cells[i][j].color
+			if f.cells[i][j].value != FixedCellValue {
+				color = 0
+			}
+			s.Cells = append(s.Cells, color)
+		}
+	}
+
+	for _, p := range b.players {
+		if p.curBlock == nil {
+			continue
+		}
+		block := netplay.Block{Color: p.curBlock.shape.color}
+		for _, cell := range p.curBlock.Cells() {
+			block.Cells = append(block.Cells, cell.Y*f.Width+cell.X)
+		}
+		s.Blocks = append(s.Blocks, block)
+	}
+	return s
+}
+
+// Restore replaces the board with a snapshot. Falling blocks of the
+// snapshot are drawn as fixed cells since remote boards don't have
+// players of their own.
+func (b *Board) Restore(s *netplay.Board) {
+	f := b.field
+	if s.Width != f.Width || s.Height != f.Height || len(s.Cells) != f.Width*f.Height {
+		log.Warning("Ignored board of size %dx%d.", s.Width, s.Height)
+		return
+	}
+
+	for i := 0; i < f.Height; i++ {
+		for j := 0; j < f.Width; j++ {
+			color := s.Cells[i*f.Width+j]
+			value := FixedCellValue
+			if color == 0 {
+				value = EmptyCellValue
+			}
+			f.Set(j, i, value, color)
+		}
+	}
+	for _, block := range s.Blocks {
+		for _, i := range block.Cells {
+			f.Set(i%f.Width, i/f.Width, FixedCellValue, block.Color)
+		}
+	}
+
+	b.stats.Level = s.Level
+	b.stats.Score = s.Score
+	b.stats.Lines = s.Lines
+	b.stats.Blocks = s.BlockCount
+	b.stats.Elapsed = s.Elapsed
+	b.pending = s.Pending
+}
+
+// sendSnapshot sends the local board to the opponent. Snapshots left
+// out while the opponent catches up are replaced by the next ones.
+func (g *Game) sendSnapshot() {
+	local := g.boards[0]
+	g.conn.TrySend(&netplay.Message{Type: netplay.MessageBoard, Board: local.Snapshot()})
+	if local.finished {
+		g.conn.Send(&netplay.Message{Type: netplay.MessageOver})
+	}
+}
+
+func (g *Game) disconnect() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	log.Warning("Opponent disconnected.")
+	g.boards[len(g.boards)-1].disconnected = true
+	if g.state != StateExiting {
+		g.state = StateFinished
+	}
+	g.redraw()
+}
+
+// receive handles messages of the opponent until the connection is
+// closed.
+func (g *Game) receive() {
+	for {
+		m, err := g.conn.Receive()
+		if err != nil || m.Type == netplay.MessageBye {
+			g.disconnect()
+			return
+		}
+
+		if m.Type == netplay.MessageRestart {
+			log.Info("Opponent restarted game.")
+			g.start()
+			continue
+		}
+
+		g.mu.Lock()
+		local, remote := g.boards[0], g.boards[1]
+		switch m.Type {
+		case netplay.MessageBoard:
+			if m.Board != nil {
+				remote.Restore(m.Board)
+			}
+		case netplay.MessageGarbage:
+			local.Receive(m.Lines)
+		case netplay.MessageOver:
+			remote.finished = true
+			if g.state == StateRunning || g.state == StatePaused {
+				g.state = StateFinished
+				log.Info("Changed state to finished.")
+			}
+		}
+		g.mu.Unlock()
+	}
+}
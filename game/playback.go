@@ -0,0 +1,298 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nsf/termbox-go"
+
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+const NormalPlaybackSpeed = 2
+
+var (
+	PlaybackSpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+)
+
+// Playback is the state of a replay being played: the next event to
+// apply, the speed, frames left to play at the current speed, the
+// frame shown on the screen and whether the replay is over.
+type Playback struct {
+	rep    *replay.Replay
+	next   int
+	speed  int
+	credit float64
+	frame  int
+	done   bool
+}
+
+// Verification compares final stats of a replayed game with the ones
+// recorded in the replay.
+type Verification struct {
+	Replay *replay.Replay
+	Score  int
+	Lines  int
+	Blocks int
+	Frames int
+}
+
+func (v *Verification) OK() bool {
+	r := v.Replay
+	return v.Score == r.Score && v.Lines == r.Lines && v.Blocks == r.Blocks && v.Frames == r.Frames
+}
+
+func (v *Verification) String() string {
+	r := v.Replay
+	if v.OK() {
+		return fmt.Sprintf(
+			"Replay verified: score %d, lines %d, blocks %d in %d frames.",
+			v.Score, v.Lines, v.Blocks, v.Frames,
+		)
+	}
+	return fmt.Sprintf(
+		"Replay mismatch: score %d (recorded %d), lines %d (recorded %d), "+
+			"blocks %d (recorded %d), frames %d (recorded %d).",
+		v.Score, r.Score, v.Lines, r.Lines, v.Blocks, r.Blocks, v.Frames, r.Frames,
+	)
+}
+
+// step applies recorded events of the current frame and advances the
+// game by a frame. A game which isn't over by the last recorded frame
+// didn't replay as recorded and is stopped.
+func (g *Game) step() {
+	pb := g.playback
+	for ; pb.next < len(pb.rep.Events); pb.next++ {
+		e := pb.rep.Events[pb.next]
+		if e.Frame > g.frame {
+			break
+		}
+		if e.Frame < g.frame || e.Player >= len(g.players) {
+			continue
+		}
+
+		p := g.players[e.Player]
+		if p.board.state == StateRunning && p.curBlock != nil {
+			p.act(e.Action)
+		}
+	}
+
+	g.update()
+	if g.state != StateFinished && g.frame >= pb.rep.Frames {
+		log.Warning("Replay isn't over after %d frames.", g.frame)
+		g.state = StateFinished
+	}
+	pb.done = g.state == StateFinished
+}
+
+// seek plays the replay from the start or the current frame without
+// drawing until piece is falling.
+func (g *Game) seek(piece int) {
+	if piece < 1 {
+		piece = 1
+	}
+
+	// Seeking back from the end of the replay leaves it paused.
+	state := g.state
+	if state == StateFinished {
+		state = StatePaused
+	}
+	if piece <= g.boards[0].stats.Blocks {
+		g.restart()
+		g.playback.next = 0
+		g.playback.done = false
+	}
+	g.state = StateRunning
+	for g.boards[0].stats.Blocks < piece && g.state == StateRunning {
+		g.step()
+	}
+	if g.state != StateFinished {
+		g.state = state
+	}
+	log.Info("Seeked to piece %d at frame %d.", g.boards[0].stats.Blocks, g.frame)
+}
+
+func (g *Game) verification() *Verification {
+	stats := g.boards[0].stats
+	return &Verification{
+		Replay: g.playback.rep,
+		Score:  stats.Score,
+		Lines:  stats.Lines,
+		Blocks: stats.Blocks,
+		Frames: g.frame,
+	}
+}
+
+// finishPlayback plays the rest of the replay without drawing.
+func (g *Game) finishPlayback() *Verification {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for !g.playback.done {
+		g.step()
+	}
+	return g.verification()
+}
+
+func (g *Game) initPlayback() {
+	g.ctrl.RegisterHandler(EventExit, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.state = StateExiting
+	})
+
+	g.ctrl.RegisterHandler(EventResize, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.screen.Resize()
+		g.redraw()
+	})
+
+	g.ctrl.RegisterHandler(EventPauseResume, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		switch g.state {
+		case StatePaused:
+			g.state = StateRunning
+		case StateRunning:
+			g.state = StatePaused
+		}
+		g.redraw()
+	})
+
+	speed := func(delta int) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		pb := g.playback
+		pb.speed += delta
+		if pb.speed < 0 {
+			pb.speed = 0
+		}
+		if pb.speed >= len(PlaybackSpeeds) {
+			pb.speed = len(PlaybackSpeeds) - 1
+		}
+		pb.credit = 0
+		g.redraw()
+	}
+	g.ctrl.RegisterHandler(EventSpeedUp, func() { speed(1) })
+	g.ctrl.RegisterHandler(EventSlowDown, func() { speed(-1) })
+
+	g.ctrl.RegisterHandler(EventStep, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.state == StatePaused {
+			g.step()
+			if g.state != StateFinished {
+				g.state = StatePaused
+			}
+			g.redraw()
+		}
+	})
+
+	seek := func(delta int) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.state != StateExiting {
+			g.seek(g.boards[0].stats.Blocks + delta)
+			g.redraw()
+		}
+	}
+	g.ctrl.RegisterHandler(EventNextPiece, func() { seek(1) })
+	g.ctrl.RegisterHandler(EventPrevPiece, func() { seek(-1) })
+}
+
+func (g *Game) runPlayback(piece int) {
+	err := g.term.Init()
+	if err != nil {
+		panic(err)
+	}
+	defer g.term.Close()
+	g.setColors()
+	if g.mouse {
+		g.term.SetMouse(true)
+		g.ctrl.SetMouse(func(e termbox.Event) {
+			g.press(e)
+		})
+	}
+
+	g.initPlayback()
+	g.mu.Lock()
+	g.restart()
+	if piece > 1 {
+		g.seek(piece)
+	}
+	g.redraw()
+	g.mu.Unlock()
+
+	go g.ctrl.Run()
+
+	ticker := time.NewTicker(FrameDelay)
+	defer ticker.Stop()
+	for range ticker.C {
+		g.mu.Lock()
+		switch g.state {
+		case StateRunning:
+			pb := g.playback
+			pb.credit += PlaybackSpeeds[pb.speed]
+			for ; pb.credit >= 1 && g.state == StateRunning; pb.credit-- {
+				g.step()
+			}
+			g.redraw()
+		case StateExiting:
+			g.mu.Unlock()
+			return
+		}
+		g.mu.Unlock()
+	}
+}
+
+func newPlayback(rep *replay.Replay, opts Options) (*Game, error) {
+	opts.Mode = rep.Mode
+	opts.Fast = rep.Fast
+	opts.Animations = rep.Animations
+	opts.Previews = rep.Previews
+	opts.Width = rep.Width
+	opts.Height = rep.Height
+	opts.StartLevel = rep.StartLevel
+	opts.EntryDelay = rep.EntryDelay
+	opts.ClearDelay = rep.ClearDelay
+	opts.Seed = rep.Seed
+	opts.Name = rep.Name
+	opts.Leaderboard = ""
+	opts.ReplayDir = ""
+	opts.HighScores = ""
+	opts.History = ""
+
+	g, err := newGame(opts)
+	if err != nil {
+		return nil, err
+	}
+	g.recorded = false
+	g.fixedSeed = true
+	g.playback = &Playback{rep: rep, speed: NormalPlaybackSpeed}
+	g.screen.playback = g.playback
+	return g, nil
+}
+
+// Play plays rep on the screen starting at the given piece and verifies
+// the replay once the player closes it.
+func Play(rep *replay.Replay, piece int, opts Options) (*Verification, error) {
+	g, err := newPlayback(rep, opts)
+	if err != nil {
+		return nil, err
+	}
+	g.runPlayback(piece)
+	return g.finishPlayback(), nil
+}
+
+// Verify plays rep without drawing and compares the result with the
+// recorded one.
+func Verify(rep *replay.Replay) (*Verification, error) {
+	g, err := newPlayback(rep, Options{Terminal: NewNullTerminal()})
+	if err != nil {
+		return nil, err
+	}
+	g.restart()
+	return g.finishPlayback(), nil
+}
@@ -0,0 +1,231 @@
+package game
+
+import (
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+// Player controls a single falling block on a board.
+type Player struct {
+	board    *Board
+	keys     KeySet
+	column   int
+	curBlock *Block
+
+	// Blocks coming after the falling one, the first of them next, and
+	// the fields they are previewed in.
+	next     []*Block
+	previews []*Field
+
+	// Left and right moves repeated by the terminal while a key is held.
+	repeat repeat
+
+	// StateRunning while the block falls or StateEntryDelay while
+	// waiting for the next block to appear.
+	state int
+	delay int
+
+	// Gravity accumulated since the last row the block fell, in
+	// 1/GravityUnit cells, and frames spent resting on the stack.
+	gravity   int
+	lockTimer int
+}
+
+// others returns falling blocks of the other players on the board.
+func (p *Player) others() (blocks []*Block) {
+	for _, o := range p.board.players {
+		if o != p && o.curBlock != nil {
+			blocks = append(blocks, o.curBlock)
+		}
+	}
+	return blocks
+}
+
+func (p *Player) spawn() {
+	b := p.board
+	scale := b.mode.Scale
+	for len(p.next) < len(p.previews) {
+		p.next = append(p.next, NewRandomBlock(PreviewLeft, PreviewTop, b.rng))
+	}
+
+	block := NewScaledBlock(p.column, 0, p.next[0].shape, scale)
+	if block.Collides(p.others()...) {
+		log.Debug("Spawn is blocked by another player.")
+		return
+	}
+
+	p.state = StateRunning
+	p.curBlock = block
+	p.next = append(p.next[1:], NewRandomBlock(PreviewLeft, PreviewTop, b.rng))
+	log.Debug("Generated new block.")
+
+	b.tryChangeLevel()
+	p.drawPreviews()
+	b.stats.Score += b.level.BlockPoints
+	b.stats.Blocks++
+	b.stats.Pieces[block.shape.name]++
+
+	b.field.Clear(false)
+	if p.curBlock.Overlaps(b.field) {
+		b.finish()
+	}
+}
+
+func (p *Player) drawPreviews() {
+	for i, preview := range p.previews {
+		preview.Clear(true)
+		if i < len(p.next) {
+			p.next[i].MustDraw(preview, false)
+		}
+	}
+}
+
+func (p *Player) enter() {
+	p.state = StateEntryDelay
+	p.delay = customDelay(p.board.level.EntryDelay, p.board.entryDelay)
+	if p.delay == 0 && p.board.state == StateRunning {
+		p.spawn()
+	}
+}
+
+func (p *Player) lock() {
+	b := p.board
+	p.curBlock.MustDraw(b.field, true)
+	if b.animate {
+		b.animations = append(b.animations, NewLockAnimation(p.curBlock.Cells()))
+	}
+	p.curBlock = nil
+	p.gravity = 0
+	p.lockTimer = 0
+
+	b.clearLines()
+	p.enter()
+}
+
+func (p *Player) TryMove(dx, dy int) bool {
+	return p.curBlock.TryMove(dx, dy, p.board.field, p.others()...)
+}
+
+func (p *Player) TryRotate() bool {
+	return p.curBlock.TryRotate(p.board.field, p.others()...)
+}
+
+// act runs one of the replay actions on the falling block.
+func (p *Player) act(action int) {
+	p.board.stats.Inputs++
+	switch action {
+	case replay.ActionLeft:
+		if p.TryMove(-1, 0) {
+			log.Debug("Moved left.")
+		} else {
+			log.Debug("Failed to move left.")
+		}
+	case replay.ActionRight:
+		if p.TryMove(1, 0) {
+			log.Debug("Moved right.")
+		} else {
+			log.Debug("Failed to move right.")
+		}
+	case replay.ActionRotate:
+		if p.TryRotate() {
+			log.Debug("Rotated.")
+		} else {
+			log.Debug("Failed to rotate.")
+		}
+	case replay.ActionDown:
+		p.moveDown()
+	}
+}
+
+func (p *Player) moveDown() {
+	ok := p.TryMove(0, 1)
+	if ok {
+		log.Debug("Moved down.")
+	} else if p.curBlock.Grounded(p.board.field) {
+		log.Debug("Failed to move down.")
+		p.lock()
+	}
+}
+
+func (p *Player) sonicDrop() (rows int) {
+	for p.TryMove(0, 1) {
+		rows++
+	}
+	return rows
+}
+
+func (p *Player) fall(rows int) (fallen int) {
+	if rows*GravityUnit >= GravitySonic {
+		return p.sonicDrop()
+	}
+	for fallen < rows && p.TryMove(0, 1) {
+		fallen++
+	}
+	return fallen
+}
+
+func (p *Player) tick() {
+	switch p.state {
+	case StateEntryDelay:
+		if p.delay > 0 {
+			p.delay--
+		}
+		if p.delay == 0 {
+			p.spawn()
+		}
+	case StateRunning:
+		p.fallTick()
+	}
+}
+
+func (p *Player) fallTick() {
+	b := p.board
+	p.gravity += b.level.Gravity
+	rows := p.gravity / GravityUnit
+	p.gravity %= GravityUnit
+
+	// Blocks resting on another player's block keep waiting instead of
+	// locking in the air.
+	grounded := p.curBlock.Grounded(b.field)
+	if rows > 0 {
+		b.stats.Score += b.level.TickPoints
+		if p.fall(rows) > 0 {
+			p.lockTimer = 0
+			grounded = p.curBlock.Grounded(b.field)
+		} else if grounded && p.lockTimer >= b.level.LockDelay {
+			log.Debug("Locked.")
+			p.lock()
+			return
+		}
+	}
+
+	if grounded {
+		p.lockTimer++
+	}
+}
+
+func (p *Player) reset() {
+	p.curBlock = nil
+	p.next = nil
+	p.repeat = repeat{}
+	p.state = StateEntryDelay
+	p.delay = 0
+	p.gravity = 0
+	p.lockTimer = 0
+}
+
+// NewPlayer returns a player of board previewing the given number of
+// next blocks.
+func NewPlayer(board *Board, keys KeySet, column, previews int) *Player {
+	p := &Player{
+		board:  board,
+		keys:   keys,
+		column: column,
+		state:  StateEntryDelay,
+	}
+	for i := 0; i < previews; i++ {
+		p.previews = append(p.previews, NewField(PreviewHeight, PreviewWidth))
+	}
+	board.players = append(board.players, p)
+	return p
+}
@@ -0,0 +1,37 @@
+package game
+
+import (
+	"math/rand"
+)
+
+// countingSource is a random source counting numbers drawn since it was
+// seeded. The seed and the count are its whole state, so a saved game
+// can continue with the same blocks and garbage it would have got.
+type countingSource struct {
+	src   rand.Source
+	seed  int64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.seed = seed
+	s.draws = 0
+}
+
+// restore seeds the source and skips numbers drawn before.
+func (s *countingSource) restore(seed int64, draws uint64) {
+	s.Seed(seed)
+	for s.draws < draws {
+		s.Int63()
+	}
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed), seed: seed}
+}
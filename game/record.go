@@ -0,0 +1,80 @@
+package game
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+func (g *Game) startRecording() {
+	if !g.recorded {
+		return
+	}
+	g.recording = &replay.Replay{
+		Seed:       g.seed,
+		Mode:       g.mode.Name,
+		Fast:       g.fast,
+		Animations: g.animate,
+		Previews:   len(g.players[0].previews),
+		Width:      g.width,
+		Height:     g.height,
+		StartLevel: g.startLevel,
+		EntryDelay: g.entryDelay,
+		ClearDelay: g.clearDelay,
+		Name:       g.name,
+	}
+}
+
+func (g *Game) record(p *Player, action int) {
+	if g.recording == nil {
+		return
+	}
+	for i, o := range g.players {
+		if o == p {
+			g.recording.Record(g.frame, i, action)
+		}
+	}
+}
+
+// finishRecording completes the replay of the finished game with its
+// final stats, saves it and submits the score with the replay attached.
+func (g *Game) finishRecording() {
+	var data []byte
+	if rep := g.recording; rep != nil {
+		g.recording = nil
+
+		stats := g.boards[0].stats
+		rep.Score = stats.Score
+		rep.Lines = stats.Lines
+		rep.Blocks = stats.Blocks
+		rep.Frames = g.frame
+		rep.Level = stats.Level
+
+		var buf bytes.Buffer
+		err := rep.Encode(&buf)
+		if err != nil {
+			log.Error("Failed to encode replay: %s.", err)
+		}
+		data = buf.Bytes()
+
+		if g.replayDir != "" {
+			dir, t := g.replayDir, time.Now()
+			go func() {
+				path, err := rep.SaveNew(dir, t)
+				if err != nil {
+					log.Error("Failed to save replay: %s.", err)
+					return
+				}
+				log.Info("Saved replay to %s.", path)
+			}()
+		}
+	}
+
+	if g.leaderboard != nil {
+		score := g.score()
+		score.Replay = data
+		g.submit(score)
+	}
+}
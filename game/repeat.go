@@ -0,0 +1,47 @@
+package game
+
+import (
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+// RepeatGap is the longest time in frames between two presses of a key
+// for the second one to be taken as the terminal repeating a held key.
+const RepeatGap = 4
+
+// repeat throttles left and right moves repeated by the terminal while a
+// key is held. Terminals don't report releases of keys, so a key counts
+// as held while its presses keep coming faster than RepeatGap.
+type repeat struct {
+	pressed bool
+	action  int
+
+	// Frames the key started being held at, it was last pressed at and
+	// the block was last moved at.
+	start int
+	last  int
+	moved int
+}
+
+// allow tells whether action pressed at frame moves the block, given
+// the delays das and arr in frames. Only moves which are allowed are
+// recorded, so replays don't depend on the delays.
+func (r *repeat) allow(action, frame, das, arr int) bool {
+	if action != replay.ActionLeft && action != replay.ActionRight {
+		return true
+	}
+
+	held := r.pressed && action == r.action && frame-r.last <= RepeatGap
+	r.pressed = true
+	r.last = frame
+	if !held {
+		r.action = action
+		r.start = frame
+		r.moved = frame
+		return true
+	}
+	if frame-r.start < das || frame-r.moved < arr {
+		return false
+	}
+	r.moved = frame
+	return true
+}
@@ -0,0 +1,343 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+const SaveVersion = 1
+
+var (
+	NoSavedGameError  = errors.New("no saved game")
+	SaveVersionError  = errors.New("unsupported saved game version")
+	CorruptSavedError = errors.New("saved game is corrupt")
+)
+
+type savedBlock struct {
+	Shape uint16  `json:"shape"`
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+	Mask  [][]int `json:"mask"`
+}
+
+// stateNames name the states of boards and players in saved games.
+var stateNames = map[int]string{
+	StateInit:       "init",
+	StateRunning:    "running",
+	StateEntryDelay: "entry-delay",
+	StateLineClear:  "line-clear",
+	StatePaused:     "paused",
+	StateFinished:   "finished",
+	StateClosed:     "closed",
+	StateExiting:    "exiting",
+	StateWaiting:    "waiting",
+	StateNameEntry:  "name-entry",
+}
+
+// parseState returns the state named name in a saved game.
+func parseState(name string) (int, error) {
+	for state, n := range stateNames {
+		if n == name {
+			return state, nil
+		}
+	}
+	return 0, CorruptSavedError
+}
+
+type savedPlayer struct {
+	Block     *savedBlock   `json:"block,omitempty"`
+	Next      *savedBlock   `json:"next,omitempty"`
+	Queue     []*savedBlock `json:"queue,omitempty"`
+	State     string        `json:"state"`
+	Delay     int           `json:"delay"`
+	Gravity   int           `json:"gravity"`
+	LockTimer int           `json:"lock_timer"`
+}
+
+type savedBoard struct {
+	Cells    []uint16      `json:"cells"`
+	Stats    Stats         `json:"stats"`
+	Level    string        `json:"level"`
+	State    string        `json:"state"`
+	Delay    int           `json:"delay"`
+	Clearing []int         `json:"clearing,omitempty"`
+	Pending  int           `json:"pending"`
+	Players  []savedPlayer `json:"players"`
+}
+
+// SavedGame is the full state of a local game between two frames,
+// including the state of its random source and the replay recorded so
+// far, so a resumed game plays exactly like it would have.
+type SavedGame struct {
+	Version    int          `json:"version"`
+	Date       time.Time    `json:"date"`
+	Mode       string       `json:"mode"`
+	Fast       bool         `json:"fast"`
+	Animations bool         `json:"animations"`
+	Previews   int          `json:"previews,omitempty"`
+	Width      int          `json:"width,omitempty"`
+	Height     int          `json:"height,omitempty"`
+	StartLevel int          `json:"start_level,omitempty"`
+	EntryDelay int          `json:"entry_delay,omitempty"`
+	ClearDelay int          `json:"clear_delay,omitempty"`
+	Seed       int64        `json:"seed"`
+	Draws      uint64       `json:"draws"`
+	Frame      int          `json:"frame"`
+	Boards     []savedBoard `json:"boards"`
+	Replay     []byte       `json:"replay,omitempty"`
+}
+
+func saveBlock(b *Block) *savedBlock {
+	if b == nil {
+		return nil
+	}
+	s := &savedBlock{Shape: b.shape.color, X: b.x, Y: b.y}
+	for _, row := range b.mask {
+		var r []int
+		for _, v := range row {
+			r = append(r, int(v))
+		}
+		s.Mask = append(s.Mask, r)
+	}
+	return s
+}
+
+func restoreBlock(s *savedBlock, scale int) (*Block, error) {
+	if s == nil {
+		return nil, nil
+	}
+	for _, shape := range Shapes {
+		if shape.color != s.Shape {
+			continue
+		}
+		b := NewScaledBlock(s.X, s.Y, shape, scale)
+		b.mask = nil
+		for _, row := range s.Mask {
+			var r []byte
+			for _, v := range row {
+				r = append(r, byte(v))
+			}
+			b.mask = append(b.mask, r)
+		}
+		return b, nil
+	}
+	return nil, CorruptSavedError
+}
+
+// snapshot saves the game. Cells of falling blocks aren't saved since
+// they are drawn from the blocks.
+func (g *Game) snapshot() *SavedGame {
+	s := &SavedGame{
+		Version:    SaveVersion,
+		Date:       time.Now(),
+		Mode:       g.mode.Name,
+		Fast:       g.fast,
+		Animations: g.animate,
+		Previews:   len(g.players[0].previews),
+		Width:      g.width,
+		Height:     g.height,
+		StartLevel: g.startLevel,
+		EntryDelay: g.entryDelay,
+		ClearDelay: g.clearDelay,
+		Seed:       g.seed,
+		Draws:      g.source.draws,
+		Frame:      g.frame,
+	}
+
+	for _, b := range g.boards {
+		sb := savedBoard{
+			Stats:    *b.stats,
+			Level:    b.level.Name,
+			State:    stateNames[b.state],
+			Delay:    b.delay,
+			Clearing: b.clearing,
+			Pending:  b.pending,
+		}
+		for _, row := range b.field.cells {
+			for _, cell := range row {
+				color := cell.color
+				if cell.value != FixedCellValue {
+					color = 0
+				}
+				sb.Cells = append(sb.Cells, color)
+			}
+		}
+		for _, p := range b.players {
+			sp := savedPlayer{
+				Block:     saveBlock(p.curBlock),
+				State:     stateNames[p.state],
+				Delay:     p.delay,
+				Gravity:   p.gravity,
+				LockTimer: p.lockTimer,
+			}
+			for i, next := range p.next {
+				if i == 0 {
+					sp.Next = saveBlock(next)
+				} else {
+					sp.Queue = append(sp.Queue, saveBlock(next))
+				}
+			}
+			sb.Players = append(sb.Players, sp)
+		}
+		s.Boards = append(s.Boards, sb)
+	}
+
+	if g.recording != nil {
+		var buf bytes.Buffer
+		err := g.recording.Encode(&buf)
+		if err != nil {
+			log.Error("Failed to encode replay: %s.", err)
+		}
+		s.Replay = buf.Bytes()
+	}
+	return s
+}
+
+// restore continues a saved game of the same mode paused.
+func (g *Game) restore(s *SavedGame) error {
+	if len(s.Boards) != len(g.boards) {
+		return CorruptSavedError
+	}
+
+	for i, b := range g.boards {
+		sb := s.Boards[i]
+		if len(sb.Cells) != b.field.Width*b.field.Height || len(sb.Players) != len(b.players) {
+			return CorruptSavedError
+		}
+
+		b.start()
+		for _, level := range b.mode.Levels {
+			if level.Name == sb.Level {
+				b.level = level
+			}
+		}
+		*b.stats = sb.Stats
+		if b.stats.Pieces == nil {
+			b.stats.Pieces = make(map[string]int)
+		}
+		state, err := parseState(sb.State)
+		if err != nil {
+			return err
+		}
+		b.state = state
+		b.delay = sb.Delay
+		b.clearing = sb.Clearing
+		b.pending = sb.Pending
+
+		for j, color := range sb.Cells {
+			if color != 0 {
+				b.field.Set(j%b.field.Width, j/b.field.Width, FixedCellValue, color)
+			}
+		}
+
+		for j, p := range b.players {
+			sp := sb.Players[j]
+			var err error
+			p.curBlock, err = restoreBlock(sp.Block, b.mode.Scale)
+			if err != nil {
+				return err
+			}
+			p.next = nil
+			for _, sn := range append([]*savedBlock{sp.Next}, sp.Queue...) {
+				next, err := restoreBlock(sn, 1)
+				if err != nil {
+					return err
+				}
+				if next != nil {
+					p.next = append(p.next, next)
+				}
+			}
+			p.state, err = parseState(sp.State)
+			if err != nil {
+				return err
+			}
+			p.delay = sp.Delay
+			p.gravity = sp.Gravity
+			p.lockTimer = sp.LockTimer
+			p.drawPreviews()
+		}
+	}
+
+	g.seed = s.Seed
+	g.source.restore(s.Seed, s.Draws)
+	g.frame = s.Frame
+	g.state = StatePaused
+	g.saved = true
+
+	g.recording = nil
+	if g.recorded && len(s.Replay) > 0 {
+		rep, err := replay.Decode(bytes.NewReader(s.Replay))
+		if err != nil {
+			log.Warning("Failed to decode replay of saved game: %s.", err)
+		} else {
+			g.recording = rep
+		}
+	}
+	log.Info("Resumed game saved at %s.", s.Date.Format(time.RFC822))
+	return nil
+}
+
+// save writes the game to the save file unless it is over or can't be
+// saved.
+func (g *Game) save() {
+	if g.saveFile == "" || !g.recorded {
+		return
+	}
+	if g.state != StateRunning && g.state != StatePaused {
+		return
+	}
+
+	data, err := json.Marshal(g.snapshot())
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(g.saveFile), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(g.saveFile, data, 0644)
+	}
+	if err != nil {
+		log.Error("Failed to save game: %s.", err)
+		return
+	}
+	g.saved = true
+	log.Info("Saved game to %s.", g.saveFile)
+}
+
+// discardSave removes the save file once the saved game is over so it
+// can't be resumed anymore.
+func (g *Game) discardSave() {
+	if !g.saved {
+		return
+	}
+	g.saved = false
+	err := os.Remove(g.saveFile)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error("Failed to remove saved game: %s.", err)
+	}
+}
+
+func LoadGame(path string) (*SavedGame, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, NoSavedGameError
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s SavedGame
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, CorruptSavedError
+	}
+	if s.Version != SaveVersion {
+		return nil, SaveVersionError
+	}
+	return &s, nil
+}
@@ -0,0 +1,93 @@
+package game
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/leaderboard"
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/replay"
+)
+
+const (
+	AnonymousName = "anonymous"
+
+	// SubmitTimeout is how long finished games wait for their scores
+	// to be submitted.
+	SubmitTimeout = leaderboard.ClientTimeout
+)
+
+// score returns the result of the finished game for the leaderboard.
+func (g *Game) score() *leaderboard.Score {
+	name := g.name
+	if name == "" {
+		name = AnonymousName
+	}
+
+	stats := g.boards[0].stats
+	return &leaderboard.Score{
+		Name:    name,
+		Mode:    g.mode.Name,
+		Seed:    g.seed,
+		Score:   stats.Score,
+		Lines:   stats.Lines,
+		Level:   stats.Level,
+		Blocks:  stats.Blocks,
+		Elapsed: stats.Elapsed,
+	}
+}
+
+// submit submits score in the background, see waitSubmissions.
+func (g *Game) submit(score *leaderboard.Score) {
+	g.submissions.Add(1)
+	go func() {
+		defer g.submissions.Done()
+		g.submitNow(score)
+	}()
+}
+
+func (g *Game) submitNow(score *leaderboard.Score) {
+	stored, err := g.leaderboard.Submit(score)
+	if err != nil {
+		log.Error("Failed to submit score: %s.", err)
+		return
+	}
+	log.Info("Submitted score %d as #%d.", stored.Score, stored.ID)
+}
+
+// waitSubmissions waits up to SubmitTimeout for scores being submitted
+// so they aren't lost when the player quits right after game over.
+func (g *Game) waitSubmissions() {
+	done := make(chan struct{})
+	go func() {
+		g.submissions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(SubmitTimeout):
+		log.Warning("Gave up submitting scores after %s.", SubmitTimeout)
+	}
+}
+
+// VerifyScore plays the replay attached to score with the seed and rules
+// it recorded and checks that it ends with the submitted result.
+func VerifyScore(score *leaderboard.Score) error {
+	rep, err := replay.Decode(bytes.NewReader(score.Replay))
+	if err != nil {
+		return err
+	}
+	if rep.Seed != score.Seed || rep.Mode != score.Mode {
+		return leaderboard.MismatchError
+	}
+
+	v, err := Verify(rep)
+	if err != nil {
+		return err
+	}
+	if !v.OK() || v.Score != score.Score || v.Lines != score.Lines ||
+		v.Blocks != score.Blocks || rep.Level != score.Level {
+		return leaderboard.MismatchError
+	}
+	return nil
+}
@@ -6,11 +6,14 @@ import (
 
     "github.com/nsf/termbox-go"
 
+    "github.com/CatWantsMeow/gtetris/highscores"
     "github.com/CatWantsMeow/gtetris/log"
 )
 
 const (
     BlockChar       = '#'
+    GhostChar       = '░'
+    GlyphColor      = termbox.ColorBlack
     BackgroundColor = termbox.ColorDefault
 
     FieldXScale   = 2
@@ -25,6 +28,8 @@ const (
     FieldBoxBottomChar = '='
     FieldBoxColor      = termbox.ColorDefault
 
+    AnimationColor = termbox.ColorWhite
+
     LeftPromptWidth  = 21
     LeftPromptLeft   = 6
     RightPromptWidth = 21
@@ -36,9 +41,52 @@ const (
     StatePausedPrompt   = "Paused"
     StateRunningPrompt  = "Running"
     StateFinishedPrompt = "Game Over"
+    StateWinnerPrompt   = "Winner"
+    StateWaitingPrompt  = "Waiting"
+    DisconnectedPrompt  = "Disconnected"
     StatePausedColor    = termbox.ColorYellow
     StateRunningColor   = termbox.ColorGreen
     StateFinishedColor  = termbox.ColorRed
+    StateWinnerColor    = termbox.ColorGreen
+    StateWaitingColor   = termbox.ColorYellow
+    DisconnectedColor   = termbox.ColorYellow
+
+    PendingGarbageColor = termbox.ColorRed
+
+    BattlePrompt = "" +
+        "Target:    %s\n" +
+        "Strategy:  %s\n" +
+        "Badges:    %d\n" +
+        "Alive:     %d/%d"
+    PlaybackPrompt = "" +
+        "Replay of %s\n" +
+        "Speed:  %gx\n" +
+        "Frame:  %d/%d\n" +
+        "Piece:  %d"
+
+    ScoresHeader      = "High scores: %s"
+    ScoresTableHeader = " #  Name          Score Lines Lvl  Time       Date"
+    ScoresRow         = "%2d. %-12s %6d %5d %3s %5s %10s"
+    ScoresEmpty       = "No scores yet."
+    ScoresHelp        = "Close:  %s"
+    NamePrompt        = "" +
+        "New high score: #%d\n" +
+        "\n" +
+        "Name: %s_\n" +
+        "\n" +
+        "Save:  enter\n" +
+        "Skip:  esc"
+    ScoresRankColor = termbox.ColorGreen
+
+    TargetedColor  = termbox.ColorRed
+    AttackingColor = termbox.ColorYellow
+
+    // Opponents of a battle royale are drawn as mini boards with two
+    // rows of cells per terminal line.
+    MiniGridColumns = 4
+    MiniBoardGap    = 1
+    MiniBoardChars  = "||"
+    MiniBoardKO     = "KO"
 
     StatsPromptHeight = 4
     StatsPrompt       = "" +
@@ -58,14 +106,9 @@ const (
         "Gogi's Tetris\n" +
         "   is 300$   "
 
-    HelpPrompt = "" +
-        "Move left:     ←\n" +
-        "Move right:    →\n" +
-        "Speed up:      ↓\n" +
-        "Rotate:        ↑\n" +
-        "Close game:    esc\n" +
-        "Pause/resume:  p\n" +
-        "Restart:       n"
+    TwoPlayerHelpPrompt = "" +
+        "Left player:   %s\n" +
+        "Right player:  %s"
 
     Header = "" +
         "\n" +
@@ -74,107 +117,312 @@ const (
         " (___/     is 300$      (___/ \n"
 )
 
+// HelpLine is a line of a help prompt showing the key of an action.
+type HelpLine struct {
+    Label  string
+    Action string
+}
+
 var (
-    colors = map[uint16]termbox.Attribute{
-        0: termbox.ColorDefault,
-        1: termbox.ColorRed,
-        2: termbox.ColorGreen,
-        3: termbox.ColorYellow,
-        4: termbox.ColorCyan,
-        5: termbox.ColorMagenta,
-        6: termbox.ColorBlue,
-        7: termbox.ColorWhite,
+    HelpPrompt = []HelpLine{
+        {"Move left", "left"},
+        {"Move right", "right"},
+        {"Speed up", "drop"},
+        {"Rotate", "rotate"},
+        {"Close game", "exit"},
+        {"Pause/resume", "pause"},
+        {"Restart", "new-game"},
+        {"Save game", "save"},
+        {"High scores", "scores"},
+    }
+    BattleHelpPrompt = []HelpLine{
+        {"Target random", "target-random"},
+        {"Target attackers", "target-attackers"},
+        {"Target KO", "target-ko"},
+    }
+    PlaybackHelpPrompt = []HelpLine{
+        {"Pause/resume", "pause"},
+        {"Speed up", "speed-up"},
+        {"Slow down", "slow-down"},
+        {"Step frame", "step"},
+        {"Prev piece", "prev-piece"},
+        {"Next piece", "next-piece"},
+        {"Close replay", "exit"},
     }
 )
 
-func NewScreen(field *Field, preview *Field, debug bool) *Screen {
+func NewScreen(term Terminal, boards []*Board, debug bool) *Screen {
     return &Screen{
-        Top:     ScreenTop,
-        Left:    ScreenMinLeft,
-        field:   field,
-        preview: preview,
-        debug:   debug,
+        Top:    ScreenTop,
+        Left:   ScreenMinLeft,
+        term:   term,
+        boards: boards,
+        keys:   DefaultKeyMap(),
+        theme:  classicTheme,
+        debug:  debug,
     }
 }
 
+// Screen lays out boards from left to right as
+// stats, board, help and, in versus mode, board and stats again.
+// The compact layout leaves out the help column, see drawCompact.
 type Screen struct {
-    debug   bool
-    stats   *Stats
-    field   *Field
-    preview *Field
+    debug  bool
+    term   Terminal
+    boards []*Board
+    keys   KeyMap
+    theme  Theme
+
+    // Ghost shows where falling blocks would land and glyphs draws
+    // letters of shapes in their cells.
+    ghost  bool
+    glyphs bool
+
+    // Battle royale layout with mini boards of the opponents.
+    battle    bool
+    strategy  string
+    opponents []*Board
+
+    playback *Playback
+    scores   *ScoresView
+
+    // Layout chosen by the player and whether the compact one is used,
+    // in auto layout when the full one doesn't fit into the terminal.
+    layout  string
+    compact bool
+
+    // Areas of the last drawn screen reacting to mouse clicks.
+    buttons []button
+    fields  []fieldArea
 
     Top  int
     Left int
 }
 
+// button is a line of a help prompt working as the key of its action
+// when clicked.
+type button struct {
+    left   int
+    top    int
+    width  int
+    action string
+}
+
+// fieldArea is where the field of a board is drawn.
+type fieldArea struct {
+    left  int
+    board *Board
+}
+
+// ButtonAt returns the action of the button at x, y or an empty string
+// if there is none.
+func (s *Screen) ButtonAt(x, y int) string {
+    for _, b := range s.buttons {
+        if y == b.top && x >= b.left && x < b.left+b.width {
+            return b.action
+        }
+    }
+    return ""
+}
+
+// ColumnAt returns the board and the column of its field at x, y.
+func (s *Screen) ColumnAt(x, y int) (*Board, int, bool) {
+    scale := FieldXScale
+    if s.compact {
+        scale = 1
+    }
+    for _, f := range s.fields {
+        field := f.board.field
+        if y < s.Top || y >= s.Top+s.boardLines() {
+            continue
+        }
+        if x >= f.left && x < f.left+field.Width*scale {
+            return f.board, (x - f.left) / scale, true
+        }
+    }
+    return nil, 0, false
+}
+
+func (s *Screen) boardWidth(b *Board) int {
+    if s.compact {
+        return len([]rune(CompactBoxChars)) + b.field.Width
+    }
+    return FieldBoxLeftWidth + b.field.Width*FieldXScale + FieldBoxRightWidth
+}
+
+// fieldHeight returns the height of the fields of the game, all of the
+// same height.
+func (s *Screen) fieldHeight() int {
+    return s.boards[0].field.Height
+}
+
+// boardLines returns the number of lines fields are drawn on.
+func (s *Screen) boardLines() int {
+    if s.compact {
+        return (s.fieldHeight() + 1) / 2
+    }
+    return s.fieldHeight() * FieldYScale
+}
+
+// height returns the number of lines of the layout below Top.
+func (s *Screen) height() int {
+    h := s.boardLines() + 1
+    if !s.compact {
+        return h
+    }
+    if s.battle {
+        if mini := s.opponentRows() * s.miniBoardHeight(); mini > h {
+            h = mini
+        }
+    }
+    return h + CompactStatusLines
+}
+
+func (s *Screen) columns() []int {
+    if s.compact {
+        cols := []int{CompactPromptWidth, s.boardWidth(s.boards[0])}
+        if s.battle {
+            cols = append(cols, MiniBoardGap+MiniGridColumns*(s.miniBoardWidth()+MiniBoardGap))
+        } else if len(s.boards) > 1 {
+            cols = append(cols, s.boardWidth(s.boards[1]), CompactPromptWidth)
+        }
+        return cols
+    }
+
+    cols := []int{LeftPromptWidth, s.boardWidth(s.boards[0]), RightPromptWidth}
+    if s.battle {
+        cols = append(cols, MiniGridColumns*(s.miniBoardWidth()+MiniBoardGap))
+    } else if len(s.boards) > 1 {
+        cols = append(cols, s.boardWidth(s.boards[1]), LeftPromptWidth)
+    }
+    return cols
+}
+
 func (s *Screen) width() int {
-    w := LeftPromptWidth +
-        FieldBoxLeftWidth +
-        s.field.Width*FieldXScale +
-        FieldBoxRightWidth +
-        RightPromptWidth
+    w := 0
+    for _, col := range s.columns() {
+        w += col
+    }
     if s.debug {
         w += LogWidth
     }
     return w
 }
 
+func (s *Screen) players() int {
+    n := 0
+    for _, b := range s.boards {
+        n += len(b.players)
+    }
+    return n
+}
+
 func (s *Screen) drawString(left, top int, str string, color termbox.Attribute) {
     lines := strings.Split(str, "\n")
     for i, line := range lines {
         str := []rune(line)
         for j, char := range str {
-            termbox.SetCell(left+j, top+i, char, color, BackgroundColor)
+            s.term.SetCell(left+j, top+i, char, color, BackgroundColor)
         }
     }
 }
 
 func (s *Screen) drawDebugInfo() {
-    bottom := s.Top + s.field.Height*FieldYScale + 2
-    header := strings.Repeat("0", LeftPromptWidth)
-    header += strings.Repeat("1", FieldBoxLeftWidth)
-    header += strings.Repeat("2", s.field.Width*FieldXScale)
-    header += strings.Repeat("3", FieldBoxRightWidth)
-    header += strings.Repeat("4", RightPromptWidth)
-    header += strings.Repeat("5", LogWidth)
+    bottom := s.Top + s.height() + 1
+    left := s.Left
+    header := ""
+    for i, col := range s.columns() {
+        header += strings.Repeat(fmt.Sprint(i), col)
+        left += col
+    }
+    header += strings.Repeat("9", LogWidth)
     s.drawString(s.Left, bottom, header, termbox.ColorDefault)
 
-    left := s.Left +
-        LeftPromptWidth +
-        FieldBoxLeftWidth +
-        s.field.Width*FieldXScale +
-        FieldBoxRightWidth +
-        RightPromptWidth
-    top := s.Top
     str := log.String(LogHeight, LogWidth-4)
-    s.drawString(left+4, top, str, termbox.ColorDefault)
+    s.drawString(left+4, s.Top, str, termbox.ColorDefault)
 }
 
-func (s *Screen) drawRightPrompt() {
-    left := s.Left +
-        LeftPromptWidth +
-        FieldBoxLeftWidth +
-        s.field.Width*FieldXScale +
-        FieldBoxRightWidth +
-        RightPromptLeft
+func (s *Screen) drawHelpPrompt(left int) {
+    left += RightPromptLeft
     s.drawString(left, s.Top, CopyrightPrompt, CopyrightPromptColor)
 
     top := s.Top + CopyrightPromptHeight + 1
-    s.drawString(left, top, HelpPrompt, termbox.ColorDefault)
+    s.drawHelp(left, top, HelpPrompt)
+
+    if s.players() > 1 {
+        top += len(HelpPrompt) + 1
+        str := fmt.Sprintf(
+            TwoPlayerHelpPrompt,
+            s.playerKeys("rotate2", "left2", "drop2", "right2"),
+            s.playerKeys("rotate", "left", "drop", "right"),
+        )
+        s.drawString(left, top, str, termbox.ColorDefault)
+    }
 }
 
-func (s *Screen) drawLeftPrompt(state int, stats *Stats) {
-    left := s.Left + LeftPromptLeft
-    top := s.Top
-    switch state {
-    case StateRunning:
+// drawHelp draws lines with keys of their actions aligned in a column.
+// Every line is a button.
+func (s *Screen) drawHelp(left, top int, lines []HelpLine) {
+    width := 0
+    for _, line := range lines {
+        if len(line.Label) > width {
+            width = len(line.Label)
+        }
+    }
+
+    for i, line := range lines {
+        str := fmt.Sprintf("%-*s%s", width+3, line.Label+":", s.keys.Label(line.Action))
+        s.drawString(left, top+i, str, termbox.ColorDefault)
+        s.buttons = append(s.buttons, button{left, top + i, len([]rune(str)), line.Action})
+    }
+}
+
+// playerKeys returns keys of a player's actions written together, e.g.
+// wasd, or separated by spaces if some of them are longer.
+func (s *Screen) playerKeys(actions ...string) string {
+    var labels []string
+    sep := ""
+    for _, action := range actions {
+        label := s.keys.Label(action)
+        if len([]rune(label)) != 1 {
+            sep = " "
+        }
+        labels = append(labels, label)
+    }
+    return strings.Join(labels, sep)
+}
+
+// drawState draws the state of the game for the player of b.
+func (s *Screen) drawState(left, top int, state int, b *Board) {
+    // In versus mode the game is over as soon as one of the boards is
+    // finished and the other one wins.
+    switch {
+    case b.disconnected:
+        s.drawString(left, top, DisconnectedPrompt, DisconnectedColor)
+    case state == StateWaiting:
+        s.drawString(left, top, StateWaitingPrompt, StateWaitingColor)
+    case state == StateFinished && s.battle && b.place == 1:
+        s.drawString(left, top, StateWinnerPrompt, StateWinnerColor)
+    case state == StateFinished && s.battle && b.place > 1:
+        str := fmt.Sprintf("%s #%d", StateFinishedPrompt, b.place)
+        s.drawString(left, top, str, StateFinishedColor)
+    case state == StateFinished && !b.finished && len(s.boards) > 1:
+        s.drawString(left, top, StateWinnerPrompt, StateWinnerColor)
+    case state == StateFinished || state == StateNameEntry:
+        s.drawString(left, top, StateFinishedPrompt, StateFinishedColor)
+    case state == StateRunning:
         s.drawString(left, top, StateRunningPrompt, StateRunningColor)
-    case StatePaused:
+    case state == StatePaused:
         s.drawString(left, top, StatePausedPrompt, StatePausedColor)
-    case StateFinished:
-        s.drawString(left, top, StateFinishedPrompt, StateFinishedColor)
     }
+}
+
+func (s *Screen) drawStatsPrompt(left int, state int, b *Board) {
+    left += LeftPromptLeft
+    top := s.Top
+    s.drawState(left, top, state, b)
 
+    stats := b.stats
     str := fmt.Sprintf(
         StatsPrompt,
         stats.Level, int(stats.Elapsed),
@@ -184,27 +432,250 @@ func (s *Screen) drawLeftPrompt(state int, stats *Stats) {
 
     top = top + 2 + StatsPromptHeight + 2
     s.drawString(left, top, NextBlockPrompt, termbox.ColorDefault)
-    s.drawField(left+NextBlockLeft, top+NextBlockTop+1, s.preview)
+
+    // Previews of every player are stacked below each other, as many
+    // of them as fit next to the board. Remote boards have no players.
+    if len(b.players) == 0 {
+        return
+    }
+    top += NextBlockTop + 1
+    height := PreviewHeight + NextBlockTop
+    shown := (s.Top + s.fieldHeight()*FieldYScale + 1 - top) / (height * len(b.players))
+    if n := len(b.players[0].previews); n < shown {
+        shown = n
+    }
+    for i, p := range b.players {
+        for j, preview := range p.previews {
+            if j < shown {
+                s.drawField(left+NextBlockLeft, top+(i*shown+j)*height, preview)
+            }
+        }
+    }
+}
+
+func (s *Screen) drawBattlePrompt(left int) {
+    left += RightPromptLeft
+    s.drawString(left, s.Top, CopyrightPrompt, CopyrightPromptColor)
+
+    target, alive := "-", 0
+    for _, b := range s.opponents {
+        if b.targeted {
+            target = b.name
+        }
+        if !b.finished {
+            alive++
+        }
+    }
+    if !s.boards[0].finished {
+        alive++
+    }
+
+    top := s.Top + CopyrightPromptHeight + 1
+    str := fmt.Sprintf(
+        BattlePrompt, target, s.strategy,
+        s.boards[0].badges, alive, len(s.opponents)+1,
+    )
+    s.drawString(left, top, str, termbox.ColorDefault)
+
+    top += strings.Count(BattlePrompt, "\n") + 2
+    s.drawHelp(left, top, BattleHelpPrompt)
+}
+
+func (s *Screen) drawPlaybackPrompt(left int) {
+    left += RightPromptLeft
+    pb := s.playback
+    s.drawString(left, s.Top, CopyrightPrompt, CopyrightPromptColor)
+
+    top := s.Top + CopyrightPromptHeight + 1
+    str := fmt.Sprintf(
+        PlaybackPrompt, pb.rep.Name, PlaybackSpeeds[pb.speed],
+        pb.frame, pb.rep.Frames, s.boards[0].stats.Blocks,
+    )
+    s.drawString(left, top, str, termbox.ColorDefault)
+
+    top += strings.Count(PlaybackPrompt, "\n") + 2
+    s.drawHelp(left, top, PlaybackHelpPrompt)
+}
+
+// drawScores draws the high score table or the name prompt over the
+// board and help prompt, or over the whole compact layout.
+func (s *Screen) drawScores(left int) {
+    view := s.scores
+    s.buttons = nil
+    s.fields = nil
+    width := s.boardWidth(s.boards[0]) + RightPromptWidth
+    lines := s.boardLines()
+    if s.compact {
+        width = s.width()
+        if width < CompactScoresWidth {
+            width = CompactScoresWidth
+        }
+        if lines < CompactScoresLines {
+            lines = CompactScoresLines
+        }
+    }
+    for i := 0; i <= lines; i++ {
+        for j := 0; j < width; j++ {
+            s.term.SetCell(left+j, s.Top+i, ' ', termbox.ColorDefault, BackgroundColor)
+        }
+    }
+
+    left += 1
+    top := s.Top + 1
+    if view.Typing {
+        s.drawString(left, top, fmt.Sprintf(NamePrompt, view.Rank, view.Name), termbox.ColorDefault)
+        return
+    }
+
+    s.drawString(left, top, fmt.Sprintf(ScoresHeader, view.Mode), CopyrightPromptColor)
+    top += 2
+    if len(view.Entries) == 0 {
+        s.drawString(left, top, ScoresEmpty, termbox.ColorDefault)
+    } else {
+        s.drawString(left, top, ScoresTableHeader, termbox.ColorDefault)
+    }
+    for i, e := range view.Entries {
+        color := termbox.ColorDefault
+        if i+1 == view.Rank {
+            color = ScoresRankColor
+        }
+        s.drawString(left, top+1+i, scoresRow(i+1, e), color)
+    }
+    str := fmt.Sprintf(ScoresHelp, s.keys.Label("scores"))
+    top = s.Top + lines - 1
+    s.drawString(left, top, str, termbox.ColorDefault)
+    s.buttons = append(s.buttons, button{left, top, len([]rune(str)), "scores"})
+}
+
+func scoresRow(rank int, e *highscores.Entry) string {
+    duration := fmt.Sprintf("%d:%02d", int(e.Duration)/60, int(e.Duration)%60)
+    return fmt.Sprintf(
+        ScoresRow, rank, e.Name, e.Score, e.Lines,
+        e.Level, duration, e.Date.Format("2006-01-02"),
+    )
+}
+
+func (s *Screen) miniBoardWidth() int {
+    return FieldWidth + len(MiniBoardChars)
+}
+
+func (s *Screen) miniBoardHeight() int {
+    // Name label, cells and bottom line.
+    return 1 + (FieldHeight+1)/2 + 1
 }
 
-func (s *Screen) drawFrame() {
-    height := s.field.Height * FieldYScale
-    width := s.field.Width * FieldXScale
+// drawMiniBoard draws a board with one terminal cell per field cell
+// using upper and lower half blocks for two rows of the field.
+func (s *Screen) drawMiniBoard(left, top int, b *Board) {
+    color := termbox.ColorDefault
+    switch {
+    case b.targeted:
+        color = TargetedColor
+    case b.attacking:
+        color = AttackingColor
+    }
+
+    label := b.name
+    if b.badges > 0 {
+        label += fmt.Sprintf(" %s", strings.Repeat("*", b.badges))
+    }
+    if w := s.miniBoardWidth(); len([]rune(label)) > w {
+        label = string([]rune(label)[:w])
+    }
+    s.drawString(left, top, label, color)
+
+    top++
+    height := (b.field.Height + 1) / 2
+    sides := []rune(MiniBoardChars)
+    for i := 0; i <= height; i++ {
+        s.term.SetCell(left, top+i, sides[0], color, BackgroundColor)
+        s.term.SetCell(left+b.field.Width+1, top+i, sides[1], color, BackgroundColor)
+    }
+    for j := 0; j < b.field.Width; j++ {
+        s.term.SetCell(left+1+j, top+height, FieldBoxBottomChar, color, BackgroundColor)
+    }
+
+    for i := 0; i < height; i++ {
+        for j := 0; j < b.field.Width; j++ {
+            _, upper, _ := b.field.Get(j, 2*i)
+            _, lower, err := b.field.Get(j, 2*i+1)
+            if err != nil {
+                lower = 0
+            }
+
+            switch {
+            case upper == 0 && lower == 0:
+                continue
+            case upper == 0:
+                s.term.SetCell(left+1+j, top+i, '▄', s.theme[lower], BackgroundColor)
+            default:
+                s.term.SetCell(left+1+j, top+i, '▀', s.theme[upper], s.theme[lower])
+            }
+        }
+    }
+
+    if b.finished {
+        str := MiniBoardKO
+        if b.place > 0 {
+            str = fmt.Sprintf("%s #%d", MiniBoardKO, b.place)
+        }
+        left += (s.miniBoardWidth() - len(str)) / 2
+        s.drawString(left, top+height/2, str, StateFinishedColor)
+    }
+}
+
+// opponentRows returns the number of rows of mini boards fitting into
+// the terminal, leaving room for status lines in the compact layout.
+func (s *Screen) opponentRows() int {
+    _, h := s.term.Size()
+    if s.compact {
+        h -= CompactStatusLines
+    }
+    rows := (h - s.Top) / s.miniBoardHeight()
+    if rows < 1 {
+        rows = 1
+    }
+    return rows
+}
+
+// drawOpponents lays out mini boards in a grid of MiniGridColumns
+// columns and as many rows as fit into the terminal.
+func (s *Screen) drawOpponents(left int) {
+    fits := s.opponentRows() * MiniGridColumns
+    if len(s.opponents) > fits {
+        fits--
+    }
+    for i, b := range s.opponents {
+        if i == fits {
+            str := fmt.Sprintf("+%d more", len(s.opponents)-fits)
+            x := left + (i%MiniGridColumns)*(s.miniBoardWidth()+MiniBoardGap)
+            y := s.Top + (i/MiniGridColumns)*s.miniBoardHeight()
+            s.drawString(x, y, str, termbox.ColorDefault)
+            break
+        }
+        x := left + (i%MiniGridColumns)*(s.miniBoardWidth()+MiniBoardGap)
+        y := s.Top + (i/MiniGridColumns)*s.miniBoardHeight()
+        s.drawMiniBoard(x, y, b)
+    }
+}
+
+func (s *Screen) drawFrame(left int, b *Board) {
+    height := b.field.Height * FieldYScale
+    width := b.field.Width * FieldXScale
 
     top := s.Top
     bottom := top + height
-    left := s.Left + LeftPromptWidth
     right := left + width + FieldBoxRightWidth
 
     for i := 0; i < height+1; i++ {
         for j, char := range FieldBoxLeftChars {
-            termbox.SetCell(
+            s.term.SetCell(
                 left+j, top+i, char,
                 FieldBoxColor, BackgroundColor,
             )
         }
         for j, char := range FieldBoxRightChars {
-            termbox.SetCell(
+            s.term.SetCell(
                 right+j, top+i, char,
                 FieldBoxColor, BackgroundColor,
             )
@@ -212,35 +683,179 @@ func (s *Screen) drawFrame() {
     }
 
     for j := 0; j < width; j++ {
-        termbox.SetCell(
+        s.term.SetCell(
             left+j+FieldBoxLeftWidth, bottom, FieldBoxBottomChar,
             FieldBoxColor, BackgroundColor,
         )
     }
+
+    // Pending garbage is shown as a meter growing up the left wall.
+    for i := 0; i < b.pending && i < height; i++ {
+        s.term.SetCell(
+            left, bottom-1-i*FieldYScale, []rune(FieldBoxLeftChars)[0],
+            FieldBoxColor, PendingGarbageColor,
+        )
+    }
+}
+
+func (s *Screen) drawCell(left, top, x, y int, color termbox.Attribute) {
+    for di := 0; di < FieldYScale; di++ {
+        for dj := 0; dj < FieldXScale; dj++ {
+            s.term.SetCell(
+                left+x*FieldXScale+dj, top+y*FieldYScale+di, ' ',
+                BackgroundColor, color,
+            )
+        }
+    }
+}
+
+// drawGlyphCell draws a cell of a field with the glyph of its color.
+func (s *Screen) drawGlyphCell(left, top, x, y int, color uint16) {
+    glyph := Glyphs[color]
+    for di := 0; di < FieldYScale; di++ {
+        for dj := 0; dj < FieldXScale; dj++ {
+            s.term.SetCell(
+                left+x*FieldXScale+dj, top+y*FieldYScale+di, glyph[dj],
+                GlyphColor, s.theme[color],
+            )
+        }
+    }
 }
 
 func (s *Screen) drawField(left, top int, field *Field) {
     for i := 0; i < field.Height; i++ {
+        for j := 0; j < field.Width; j++ {
+            _, color, err := field.Get(j, i)
+            if err != nil {
+                panic(err)
+            }
+            if s.glyphs && color != 0 {
+                s.drawGlyphCell(left, top, j, i, color)
+            } else {
+                s.drawCell(left, top, j, i, s.theme[color])
+            }
+        }
+    }
+}
+
+// ghostCells returns empty cells of the field the falling block of p
+// would take if it landed.
+func (s *Screen) ghostCells(p *Player) []Point {
+    if p.curBlock == nil {
+        return nil
+    }
+
+    field := p.board.field
+    ghost := *p.curBlock
+    for ghost.TryMove(0, 1, field, p.others()...) {
+    }
+    var cells []Point
+    for _, cell := range ghost.Cells() {
+        value, _, err := field.Get(cell.X, cell.Y)
+        if err == nil && value == EmptyCellValue {
+            cells = append(cells, cell)
+        }
+    }
+    return cells
+}
+
+// drawGhost draws the falling block of p where it would land over empty
+// cells of the field.
+func (s *Screen) drawGhost(left, top int, p *Player) {
+    if p.curBlock == nil {
+        return
+    }
+
+    ghost := p.curBlock
+    for _, cell := range s.ghostCells(p) {
         for dj := 0; dj < FieldXScale; dj++ {
-            for j := 0; j < field.Width; j++ {
-                for di := 0; di < FieldYScale; di++ {
-                    x := left + j*FieldXScale + dj
-                    y := top + i*FieldYScale + di
-
-                    _, color, err := field.Get(j, i)
-                    if err != nil {
-                        panic(err)
-                    }
+            s.term.SetCell(
+                left+cell.X*FieldXScale+dj, top+cell.Y*FieldYScale, GhostChar,
+                s.theme[ghost.shape.color], BackgroundColor,
+            )
+        }
+    }
+}
+
+func (s *Screen) drawAnimations(left, top int, b *Board) {
+    s.animate(b, func(x, y int, color termbox.Attribute) {
+        s.drawCell(left, top, x, y, color)
+    })
+}
 
-                    termbox.SetCell(x, y, ' ', BackgroundColor, colors[color])
+// animate sets colors of cells of b taken by its animations with set.
+func (s *Screen) animate(b *Board, set func(x, y int, color termbox.Attribute)) {
+    width := b.field.Width
+    for _, a := range b.animations {
+        switch a.Kind {
+        case AnimationLock:
+            for _, cell := range a.Cells {
+                set(cell.X, cell.Y, AnimationColor)
+            }
+        case AnimationLineFlash:
+            if a.Frame%4 < 2 {
+                for _, i := range a.Lines {
+                    for j := 0; j < width; j++ {
+                        set(j, i, AnimationColor)
+                    }
+                }
+            }
+        case AnimationLineClear:
+            // Rows flash for the first third of the animation and are
+            // then wiped out from the center towards the walls.
+            flash := a.Frames / 3
+            flashing := a.Frame < flash
+            half := (width + 1) / 2
+            wiped := half * (a.Frame + 1 - flash) / (a.Frames - flash)
+            for _, i := range a.Lines {
+                for j := 0; j < width; j++ {
+                    if flashing {
+                        if a.Frame%4 < 2 {
+                            set(j, i, AnimationColor)
+                        }
+                    } else if j >= width/2-wiped && j < half+wiped {
+                        set(j, i, BackgroundColor)
+                    } else {
+                        set(j, i, AnimationColor)
+                    }
                 }
             }
         }
     }
 }
 
+func (s *Screen) drawBoard(left int, b *Board) {
+    s.drawFrame(left, b)
+    left += FieldBoxLeftWidth
+    s.fields = append(s.fields, fieldArea{left, b})
+    s.drawField(left, s.Top, b.field)
+    if s.ghost {
+        for _, p := range b.players {
+            s.drawGhost(left, s.Top, p)
+        }
+    }
+    s.drawAnimations(left, s.Top, b)
+}
+
+// Resize centers the layout in the terminal, switching to the compact
+// one in auto layout if the full one doesn't fit. Auto layout keeps the
+// full one with glyphs on, as the compact one has no room for them.
 func (s *Screen) Resize() {
-    w, _ := termbox.Size()
+    w, h := s.term.Size()
+    switch s.layout {
+    case LayoutFull:
+        s.compact = false
+    case LayoutCompact:
+        s.compact = true
+    default:
+        s.compact = false
+        s.compact = !s.glyphs && (s.width() > w || ScreenTop+s.height() > h)
+    }
+    s.Top = ScreenTop
+    if s.compact {
+        s.Top = CompactTop
+    }
+
     left := (w - s.width()) / 2
     if ScreenMinLeft < left {
         s.Left = left
@@ -249,28 +864,62 @@ func (s *Screen) Resize() {
     }
 }
 
-func (s *Screen) Draw(state int, stats *Stats) {
-    err := termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+func (s *Screen) Draw(state int) {
+    err := s.term.Clear(termbox.ColorDefault, termbox.ColorDefault)
     if err != nil {
         panic(err)
     }
 
     s.Resize()
-    s.drawFrame()
-    s.drawRightPrompt()
-    s.drawLeftPrompt(state, stats)
+    s.buttons = nil
+    s.fields = nil
+    if s.compact {
+        s.drawCompact(state)
+        if s.scores != nil {
+            s.drawScores(s.Left)
+        }
+        s.flush()
+        return
+    }
+
+    left := s.Left
+    s.drawStatsPrompt(left, state, s.boards[0])
+    left += LeftPromptWidth
+    boardLeft := left
+    s.drawBoard(left, s.boards[0])
+    left += s.boardWidth(s.boards[0])
+    if s.battle {
+        s.drawBattlePrompt(left)
+    } else if s.playback != nil {
+        s.drawPlaybackPrompt(left)
+    } else {
+        s.drawHelpPrompt(left)
+    }
+    left += RightPromptWidth
+
+    if s.battle {
+        s.drawOpponents(left)
+    } else if len(s.boards) > 1 {
+        s.drawBoard(left, s.boards[1])
+        left += s.boardWidth(s.boards[1])
+        s.drawStatsPrompt(left, state, s.boards[1])
+    }
 
+    if s.scores != nil {
+        s.drawScores(boardLeft)
+    }
+
+    s.flush()
+}
+
+func (s *Screen) flush() {
     if s.debug {
         s.drawDebugInfo()
     }
 
-    top := s.Top
-    left := s.Left + LeftPromptWidth + FieldBoxLeftWidth
-    s.drawField(left, top, s.field)
-
-    err = termbox.Flush()
+    err := s.term.Flush()
     if err != nil {
         panic(err)
     }
-    termbox.SetCursor(0, 0)
+    s.term.SetCursor(0, 0)
 }
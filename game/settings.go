@@ -0,0 +1,200 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	SettingsTitle = "Settings"
+	SettingsRow   = "%-12s < %s >"
+	SettingsHelp  = "Change: left/right, enter   Back: esc"
+
+	// MaxRepeatDelay is the longest DAS and ARR in frames.
+	MaxRepeatDelay = 30
+)
+
+// Settings are options of the player changed in the settings screen.
+type Settings struct {
+	Ghost      bool
+	Previews   int
+	DAS        int
+	ARR        int
+	Theme      string
+	Glyphs     bool
+	Layout     string
+	Sound      bool
+	Animations bool
+	Mouse      bool
+}
+
+// setting is a row of the settings screen.
+type setting struct {
+	label  string
+	value  func(s *Settings) string
+	change func(s *Settings, delta int)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// layout returns the layout of s, saying how it goes with glyphs.
+func layout(s *Settings) string {
+	switch {
+	case !s.Glyphs:
+		return s.Layout
+	case s.Layout == LayoutCompact:
+		return s.Layout + ", no glyphs"
+	case s.Layout == LayoutAuto:
+		return s.Layout + ", full with glyphs"
+	}
+	return s.Layout
+}
+
+func frames(n int) string {
+	if n == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d frames", n)
+}
+
+// clamp adds delta to v wrapping around within [low, high].
+func clamp(v, delta, low, high int) int {
+	v += delta
+	switch {
+	case v < low:
+		return high
+	case v > high:
+		return low
+	}
+	return v
+}
+
+var settings = []setting{
+	{
+		"Ghost piece",
+		func(s *Settings) string { return onOff(s.Ghost) },
+		func(s *Settings, delta int) { s.Ghost = !s.Ghost },
+	},
+	{
+		"Previews",
+		func(s *Settings) string { return strconv.Itoa(s.Previews) },
+		func(s *Settings, delta int) { s.Previews = clamp(s.Previews, delta, 1, MaxPreviews) },
+	},
+	{
+		"DAS",
+		func(s *Settings) string { return frames(s.DAS) },
+		func(s *Settings, delta int) { s.DAS = clamp(s.DAS, delta, 0, MaxRepeatDelay) },
+	},
+	{
+		"ARR",
+		func(s *Settings) string { return frames(s.ARR) },
+		func(s *Settings, delta int) { s.ARR = clamp(s.ARR, delta, 0, MaxRepeatDelay) },
+	},
+	{
+		"Theme",
+		func(s *Settings) string { return s.Theme },
+		func(s *Settings, delta int) {
+			i := 0
+			for j, name := range ThemeNames {
+				if name == s.Theme {
+					i = j
+				}
+			}
+			s.Theme = ThemeNames[clamp(i, delta, 0, len(ThemeNames)-1)]
+		},
+	},
+	{
+		"Glyphs",
+		func(s *Settings) string { return onOff(s.Glyphs) },
+		func(s *Settings, delta int) { s.Glyphs = !s.Glyphs },
+	},
+	{
+		"Layout",
+		layout,
+		func(s *Settings, delta int) {
+			i := 0
+			for j, name := range LayoutNames {
+				if name == s.Layout {
+					i = j
+				}
+			}
+			s.Layout = LayoutNames[clamp(i, delta, 0, len(LayoutNames)-1)]
+		},
+	},
+	{
+		"Sound",
+		func(s *Settings) string { return onOff(s.Sound) },
+		func(s *Settings, delta int) { s.Sound = !s.Sound },
+	},
+	{
+		"Animations",
+		func(s *Settings) string { return onOff(s.Animations) },
+		func(s *Settings, delta int) { s.Animations = !s.Animations },
+	},
+	{
+		"Mouse",
+		func(s *Settings) string { return onOff(s.Mouse) },
+		func(s *Settings, delta int) { s.Mouse = !s.Mouse },
+	},
+}
+
+// EditSettings shows s in the settings screen on an initialized term
+// until the player goes back and returns the changed settings.
+func EditSettings(term Terminal, s Settings) Settings {
+	if s.Previews < 1 {
+		s.Previews = 1
+	}
+	if s.Theme == "" {
+		s.Theme = DefaultTheme
+	}
+	if s.Layout == "" {
+		s.Layout = LayoutAuto
+	}
+
+	menu := &Menu{Title: SettingsTitle, Help: SettingsHelp}
+	for {
+		menu.Items = nil
+		for _, row := range settings {
+			menu.Items = append(menu.Items, fmt.Sprintf(SettingsRow, row.label, row.value(&s)))
+		}
+		first := menu.draw(term)
+
+		e := term.PollEvent()
+		delta := 0
+		switch e.Type {
+		case termbox.EventError:
+			return s
+		case termbox.EventMouse:
+			if _, ok := menu.click(e, first); ok {
+				delta = 1
+			}
+		case termbox.EventKey:
+			switch {
+			case e.Key == termbox.KeyArrowUp || e.Ch == 'k':
+				menu.Selected = (menu.Selected + len(settings) - 1) % len(settings)
+			case e.Key == termbox.KeyArrowDown || e.Ch == 'j':
+				menu.Selected = (menu.Selected + 1) % len(settings)
+			case e.Key == termbox.KeyArrowLeft || e.Ch == 'h':
+				delta = -1
+			case e.Key == termbox.KeyArrowRight || e.Ch == 'l':
+				delta = 1
+			case e.Key == termbox.KeyEnter || e.Key == termbox.KeySpace:
+				delta = 1
+			case e.Key == termbox.KeyEsc || e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD:
+				return s
+			}
+		}
+
+		if delta != 0 {
+			settings[menu.Selected].change(&s, delta)
+			term.SetMouse(s.Mouse)
+		}
+	}
+}
@@ -11,6 +11,7 @@ var (
             {0, 1, 1},
         },
         color: 1,
+        name:  "Z",
     }
     SShape = Shape{
         mask: [][]byte{
@@ -18,6 +19,7 @@ var (
             {1, 1, 0},
         },
         color: 2,
+        name:  "S",
     }
     OShape = Shape{
         mask: [][]byte{
@@ -25,12 +27,14 @@ var (
             {1, 1},
         },
         color: 3,
+        name:  "O",
     }
     IShape = Shape{
         mask: [][]byte{
             {1, 1, 1, 1},
         },
         color: 4,
+        name:  "I",
     }
     TShape = Shape{
         mask: [][]byte{
@@ -38,6 +42,7 @@ var (
             {1, 1, 1},
         },
         color: 5,
+        name:  "T",
     }
     JShape = Shape{
         mask: [][]byte{
@@ -45,6 +50,7 @@ var (
             {1, 1, 1},
         },
         color: 6,
+        name:  "J",
     }
     LShape = Shape{
         mask: [][]byte{
@@ -52,6 +58,7 @@ var (
             {1, 0, 0},
         },
         color: 7,
+        name:  "L",
     }
     Shapes = []Shape{
         ZShape, SShape,
@@ -64,11 +71,13 @@ var (
 type Shape struct {
     mask  [][]byte
     color uint16
+    name  string
 }
 
 type Block struct {
     x     int
     y     int
+    scale int
     mask  [][]byte
     shape Shape
 }
@@ -82,30 +91,36 @@ func (b *Block) pos() (x int, y int) {
     if len(b.mask[0])%2 == 0 {
         dx--
     }
-    return b.x - dx, b.y - dy
+    return b.x - dx*b.scale, b.y - dy*b.scale
 }
 
 func (b *Block) Draw(field *Field, fixed bool) error {
+    val := MovingCellValue
+    if fixed {
+        val = FixedCellValue
+    }
+
+    for _, cell := range b.Cells() {
+        err := field.Set(cell.X, cell.Y, val, b.shape.color)
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Cells returns field coordinates of every cell covered by the block,
+// each mask cell covering scale x scale field cells.
+func (b *Block) Cells() (cells []Point) {
     x, y := b.pos()
-    for i := 0; i < len(b.mask); i++ {
-        for j := 0; j < len(b.mask[i]); j++ {
-            if b.mask[i][j] != 0 {
-                x := x + j
-                y := y + i
-
-                val := MovingCellValue
-                if fixed {
-                    val = FixedCellValue
-                }
-
-                err := field.Set(x, y, val, b.shape.color)
-                if err != nil {
-                    return err
-                }
+    for i := 0; i < len(b.mask)*b.scale; i++ {
+        for j := 0; j < len(b.mask[0])*b.scale; j++ {
+            if b.mask[i/b.scale][j/b.scale] != 0 {
+                cells = append(cells, Point{X: x + j, Y: y + i})
             }
         }
     }
-    return nil
+    return cells
 }
 
 func (b *Block) MustDraw(field *Field, fixed bool) {
@@ -115,15 +130,39 @@ func (b *Block) MustDraw(field *Field, fixed bool) {
     }
 }
 
-func (b *Block) Overlaps(field *Field) bool {
+// Collides reports whether the block shares a cell with any of others.
+func (b *Block) Collides(others ...*Block) bool {
+    if len(others) == 0 {
+        return false
+    }
+
+    cells := make(map[Point]bool)
+    for _, cell := range b.Cells() {
+        cells[cell] = true
+    }
+    for _, other := range others {
+        for _, cell := range other.Cells() {
+            if cells[cell] {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+func (b *Block) Overlaps(field *Field, others ...*Block) bool {
+    if b.Collides(others...) {
+        return true
+    }
+
     x, y := b.pos()
-    for i := 0; i < len(b.mask); i++ {
-        for j := 0; j < len(b.mask[i]); j++ {
+    for i := 0; i < len(b.mask)*b.scale; i++ {
+        for j := 0; j < len(b.mask[0])*b.scale; j++ {
             val, _, err := field.Get(x+j, y+i)
             if err != nil {
                 return true
             }
-            if b.mask[i][j] > 0 && val == FixedCellValue {
+            if b.mask[i/b.scale][j/b.scale] > 0 && val == FixedCellValue {
                 return true
             }
         }
@@ -131,18 +170,38 @@ func (b *Block) Overlaps(field *Field) bool {
     return false
 }
 
-func (b *Block) TryMove(dx, dy int, field *Field) bool {
-    b.x += dx
-    b.y += dy
-    if b.Overlaps(field) {
-        b.x -= dx
-        b.y -= dy
+func (b *Block) TryMove(dx, dy int, field *Field, others ...*Block) bool {
+    b.x += dx * b.scale
+    b.y += dy * b.scale
+    if b.Overlaps(field, others...) {
+        b.x -= dx * b.scale
+        b.y -= dy * b.scale
         return false
     }
     return true
 }
 
-func (b *Block) TryRotate(field *Field) bool {
+func (b *Block) Grounded(field *Field) bool {
+    b.y += b.scale
+    grounded := b.Overlaps(field)
+    b.y -= b.scale
+    return grounded
+}
+
+// Raise moves the block up until it no longer overlaps the field.
+func (b *Block) Raise(field *Field) bool {
+    y := b.y
+    for b.Overlaps(field) {
+        b.y -= b.scale
+        if b.y < 0 {
+            b.y = y
+            return false
+        }
+    }
+    return true
+}
+
+func (b *Block) TryRotate(field *Field, others ...*Block) bool {
     n := len(b.mask)
     m := len(b.mask[0])
 
@@ -159,7 +218,7 @@ func (b *Block) TryRotate(field *Field) bool {
 
     old := b.mask
     b.mask = rotated
-    if b.Overlaps(field) {
+    if b.Overlaps(field, others...) {
         b.mask = old
         return false
     }
@@ -167,10 +226,14 @@ func (b *Block) TryRotate(field *Field) bool {
 }
 
 func (b *Block) Copy(x, y int) *Block {
-    return NewBlock(x, y, b.shape)
+    return NewScaledBlock(x, y, b.shape, b.scale)
 }
 
 func NewBlock(x, y int, shape Shape) *Block {
+    return NewScaledBlock(x, y, shape, 1)
+}
+
+func NewScaledBlock(x, y int, shape Shape, scale int) *Block {
     n := len(shape.mask)
     m := len(shape.mask[0])
 
@@ -183,12 +246,13 @@ func NewBlock(x, y int, shape Shape) *Block {
     return &Block{
         x:     x,
         y:     y,
+        scale: scale,
         mask:  mask,
         shape: shape,
     }
 }
 
-func NewRandomBlock(x, y int) *Block {
-    i := rand.Intn(len(Shapes))
+func NewRandomBlock(x, y int, rng *rand.Rand) *Block {
+    i := rng.Intn(len(Shapes))
     return NewBlock(x, y, Shapes[i])
 }
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/log"
+	"github.com/CatWantsMeow/gtetris/netplay"
+)
+
+func (g *Game) publish() {
+	g.spectators.Publish(g.boards[0].Snapshot())
+}
+
+// watch applies boards streamed by the watched game until the
+// connection is closed.
+func (g *Game) watch() {
+	var last *netplay.Board
+	for {
+		m, err := g.conn.Receive()
+		if err != nil || m.Type == netplay.MessageBye {
+			g.disconnect()
+			return
+		}
+
+		switch m.Type {
+		case netplay.MessageBoard:
+			last = m.Board
+		case netplay.MessageDelta:
+			if last == nil {
+				log.Warning("Ignored delta without a board.")
+				continue
+			}
+			last = last.Apply(m.Board)
+		default:
+			continue
+		}
+
+		g.mu.Lock()
+		board := g.boards[0]
+		// Games with a custom field size are watched on a field of
+		// their size.
+		f := board.field
+		if (last.Width != f.Width || last.Height != f.Height) &&
+			last.Width >= MinFieldWidth && last.Width <= MaxFieldWidth &&
+			last.Height >= MinFieldHeight && last.Height <= MaxFieldHeight {
+			board.field = NewField(last.Height, last.Width)
+			g.screen.Resize()
+		}
+		board.Restore(last)
+		board.finished = last.Finished
+		if board.finished {
+			g.state = StateFinished
+		} else {
+			g.state = StateRunning
+		}
+		g.redraw()
+		g.mu.Unlock()
+	}
+}
+
+// Watch shows a game streamed by another gtetris process read-only.
+func Watch(conn *netplay.Conn, debug bool) error {
+	term := NewTermboxTerminal()
+	err := term.Init()
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	field := NewField(FieldHeight, FieldWidth)
+	board := NewBoard(field, &classicMode, false, false)
+	board.remote = true
+
+	g := Game{
+		term:   term,
+		boards: []*Board{board},
+		screen: NewScreen(term, []*Board{board}, debug),
+		ctrl:   NewController(term),
+		state:  StateRunning,
+		mode:   &classicMode,
+		conn:   conn,
+		debug:  debug,
+	}
+
+	exit := make(chan bool, 1)
+	g.ctrl.RegisterHandler(EventExit, func() {
+		exit <- true
+	})
+	g.ctrl.RegisterHandler(EventResize, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.screen.Resize()
+		g.redraw()
+	})
+
+	go g.ctrl.Run()
+	go g.watch()
+
+	g.mu.Lock()
+	g.redraw()
+	g.mu.Unlock()
+
+	// Redraw periodically so the log stays up to date in debug mode.
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exit:
+			return nil
+		case <-ticker.C:
+			g.mu.Lock()
+			g.redraw()
+			g.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package game
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Terminal is where a game is drawn and where its input comes from.
+type Terminal interface {
+	Init() error
+	Close()
+	Size() (width int, height int)
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	SetCursor(x, y int)
+	Clear(fg, bg termbox.Attribute) error
+	Flush() error
+	PollEvent() termbox.Event
+
+	// SetMouse turns reporting of mouse events on or off.
+	SetMouse(enabled bool)
+
+	// Bell rings the bell of the terminal.
+	Bell()
+
+	// SetColorMode shows as many colors as the terminal supports up to
+	// mode and returns the mode it shows.
+	SetColorMode(mode ColorMode) ColorMode
+}
+
+// termboxTerminal draws to the terminal of the process.
+type termboxTerminal struct {
+	mode ColorMode
+}
+
+func (t *termboxTerminal) Init() error {
+	return termbox.Init()
+}
+
+func (t *termboxTerminal) Close() {
+	termbox.Close()
+}
+
+func (t *termboxTerminal) Size() (int, int) {
+	return termbox.Size()
+}
+
+func (t *termboxTerminal) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, t.color(fg), t.color(bg))
+}
+
+func (t *termboxTerminal) SetCursor(x, y int) {
+	termbox.SetCursor(x, y)
+}
+
+func (t *termboxTerminal) Clear(fg, bg termbox.Attribute) error {
+	return termbox.Clear(t.color(fg), t.color(bg))
+}
+
+func (t *termboxTerminal) Flush() error {
+	return termbox.Flush()
+}
+
+func (t *termboxTerminal) PollEvent() termbox.Event {
+	return termbox.PollEvent()
+}
+
+func (t *termboxTerminal) SetMouse(enabled bool) {
+	mode := termbox.InputEsc
+	if enabled {
+		mode |= termbox.InputMouse
+	}
+	termbox.SetInputMode(mode)
+}
+
+func (t *termboxTerminal) Bell() {
+	os.Stdout.WriteString("\a")
+}
+
+func (t *termboxTerminal) SetColorMode(mode ColorMode) ColorMode {
+	output := termbox.OutputNormal
+	switch mode {
+	case Colors256:
+		output = termbox.Output256
+	case ColorsTrue:
+		output = termbox.OutputRGB
+	}
+	termbox.SetOutputMode(output)
+	t.mode = mode
+	return mode
+}
+
+// color returns the attribute showing color in the current mode. Basic
+// colors are RGB ones like any other in true color mode.
+func (t *termboxTerminal) color(color termbox.Attribute) termbox.Attribute {
+	if t.mode != ColorsTrue {
+		return color
+	}
+	styles := termbox.AttrBold | termbox.AttrUnderline | termbox.AttrReverse
+	c := color &^ styles
+	if c < termbox.ColorBlack || c > termbox.ColorLightGray {
+		return color
+	}
+	r, g, b := splitRGB(basicRGB[c-termbox.ColorBlack])
+	return termbox.RGBToAttribute(uint8(r), uint8(g), uint8(b)) | color&styles
+}
+
+func NewTermboxTerminal() Terminal {
+	return &termboxTerminal{}
+}
+
+// nullTerminal draws nothing and has no input, e.g. for bots.
+type nullTerminal struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func (t *nullTerminal) Init() error {
+	return nil
+}
+
+func (t *nullTerminal) Close() {
+	t.once.Do(func() {
+		close(t.done)
+	})
+}
+
+func (t *nullTerminal) Size() (int, int) {
+	return 0, 0
+}
+
+func (t *nullTerminal) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {}
+
+func (t *nullTerminal) SetCursor(x, y int) {}
+
+func (t *nullTerminal) Clear(fg, bg termbox.Attribute) error {
+	return nil
+}
+
+func (t *nullTerminal) Flush() error {
+	return nil
+}
+
+func (t *nullTerminal) PollEvent() termbox.Event {
+	<-t.done
+	return termbox.Event{Type: termbox.EventError, Err: io.EOF}
+}
+
+func (t *nullTerminal) SetMouse(enabled bool) {}
+
+func (t *nullTerminal) Bell() {}
+
+func (t *nullTerminal) SetColorMode(mode ColorMode) ColorMode {
+	return mode
+}
+
+func NewNullTerminal() Terminal {
+	return &nullTerminal{done: make(chan struct{})}
+}
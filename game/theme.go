@@ -0,0 +1,368 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	DefaultTheme = "classic"
+
+	// ColorsAuto picks the color mode from the environment.
+	ColorsAuto = "auto"
+)
+
+// ColorMode is how many colors a terminal shows, from the fewest to the
+// most.
+type ColorMode int
+
+const (
+	ColorsBasic ColorMode = iota
+	Colors256
+	ColorsTrue
+)
+
+var (
+	UnknownThemeError     = errors.New("unknown theme")
+	UnknownColorModeError = errors.New("unknown color mode, expected auto, basic, 256 or truecolor")
+)
+
+var colorModes = map[string]ColorMode{
+	"basic":     ColorsBasic,
+	"256":       Colors256,
+	"truecolor": ColorsTrue,
+}
+
+// String returns the name of the color mode.
+func (m ColorMode) String() string {
+	for name, mode := range colorModes {
+		if mode == m {
+			return name
+		}
+	}
+	return strconv.Itoa(int(m))
+}
+
+// TerminalColorMode guesses the colors a terminal of type term shows,
+// as TERM names it, with COLORTERM set to colorterm.
+func TerminalColorMode(term, colorterm string) ColorMode {
+	switch colorterm {
+	case "truecolor", "24bit":
+		return ColorsTrue
+	}
+	if strings.Contains(term, "256color") {
+		return Colors256
+	}
+	return ColorsBasic
+}
+
+// DetectColorMode guesses the colors the terminal of the process shows
+// from COLORTERM and TERM.
+func DetectColorMode() ColorMode {
+	return TerminalColorMode(os.Getenv("TERM"), os.Getenv("COLORTERM"))
+}
+
+// ParseColorMode returns the color mode named name, detecting it if the
+// name is ColorsAuto or empty.
+func ParseColorMode(name string) (ColorMode, error) {
+	if name == "" || name == ColorsAuto {
+		return DetectColorMode(), nil
+	}
+	mode, ok := colorModes[name]
+	if !ok {
+		return 0, UnknownColorModeError
+	}
+	return mode, nil
+}
+
+// basicRGB are the basic terminal colors from black to light gray as
+// xterm shows them.
+var basicRGB = []int{
+	0x000000, 0xcd0000, 0x00cd00, 0xcdcd00, 0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+	0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00, 0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+}
+
+func splitRGB(rgb int) (int, int, int) {
+	return rgb >> 16 & 0xff, rgb >> 8 & 0xff, rgb & 0xff
+}
+
+func rgbDistance(a, b int) int {
+	ar, ag, ab := splitRGB(a)
+	br, bg, bb := splitRGB(b)
+	return (ar-br)*(ar-br) + (ag-bg)*(ag-bg) + (ab-bb)*(ab-bb)
+}
+
+// nearestBasic returns the basic terminal color closest to rgb.
+func nearestBasic(rgb int) termbox.Attribute {
+	best := 0
+	for i, c := range basicRGB {
+		if rgbDistance(rgb, c) < rgbDistance(rgb, basicRGB[best]) {
+			best = i
+		}
+	}
+	return termbox.ColorBlack + termbox.Attribute(best)
+}
+
+// nearest256 returns the color of the 6x6x6 cube or the gray ramp of
+// 256 color terminals closest to rgb.
+func nearest256(rgb int) termbox.Attribute {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	level := func(v int) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (v - 35) / 40
+	}
+
+	r, g, b := splitRGB(rgb)
+	cube := 16 + 36*level(r) + 6*level(g) + level(b)
+	cubeRGB := levels[level(r)]<<16 | levels[level(g)]<<8 | levels[level(b)]
+
+	gray := (r+g+b)/3 - 3
+	if gray < 0 {
+		gray = 0
+	}
+	gray /= 10
+	if gray > 23 {
+		gray = 23
+	}
+	v := 8 + gray*10
+	if rgbDistance(rgb, v<<16|v<<8|v) < rgbDistance(rgb, cubeRGB) {
+		cube = 232 + gray
+	}
+	// Colors of 256 color terminals are numbered from one like the
+	// basic ones.
+	return termbox.Attribute(cube + 1)
+}
+
+// themeColor is a color of a palette shown as RGB where the terminal
+// supports it and as a basic color otherwise. Basic only colors have a
+// negative rgb.
+type themeColor struct {
+	rgb   int
+	basic termbox.Attribute
+}
+
+func basic(color termbox.Attribute) themeColor {
+	return themeColor{-1, color}
+}
+
+func rgb(value int, color termbox.Attribute) themeColor {
+	return themeColor{value, color}
+}
+
+func (c themeColor) attribute(mode ColorMode) termbox.Attribute {
+	switch {
+	case c.rgb < 0 || mode == ColorsBasic:
+		return c.basic
+	case mode == Colors256:
+		return nearest256(c.rgb)
+	}
+	r, g, b := splitRGB(c.rgb)
+	return termbox.RGBToAttribute(uint8(r), uint8(g), uint8(b))
+}
+
+// palette holds colors of shapes and garbage cells of a theme.
+type palette map[uint16]themeColor
+
+var (
+	classicPalette = palette{
+		1:                basic(termbox.ColorRed),
+		2:                basic(termbox.ColorGreen),
+		3:                basic(termbox.ColorYellow),
+		4:                basic(termbox.ColorCyan),
+		5:                basic(termbox.ColorMagenta),
+		6:                basic(termbox.ColorBlue),
+		7:                basic(termbox.ColorWhite),
+		GarbageCellColor: basic(termbox.ColorDarkGray),
+	}
+	guidelinePalette = palette{
+		1:                rgb(0xf00000, termbox.ColorRed),
+		2:                rgb(0x00f000, termbox.ColorGreen),
+		3:                rgb(0xf0f000, termbox.ColorYellow),
+		4:                rgb(0x00f0f0, termbox.ColorCyan),
+		5:                rgb(0xa000f0, termbox.ColorMagenta),
+		6:                rgb(0x0000f0, termbox.ColorBlue),
+		7:                rgb(0xf0a000, termbox.ColorLightRed),
+		GarbageCellColor: rgb(0x808080, termbox.ColorDarkGray),
+	}
+	monochromePalette = palette{
+		1:                basic(termbox.ColorWhite),
+		2:                basic(termbox.ColorWhite),
+		3:                basic(termbox.ColorWhite),
+		4:                basic(termbox.ColorWhite),
+		5:                basic(termbox.ColorWhite),
+		6:                basic(termbox.ColorWhite),
+		7:                basic(termbox.ColorWhite),
+		GarbageCellColor: basic(termbox.ColorDarkGray),
+	}
+	highContrastPalette = palette{
+		1:                rgb(0xff0000, termbox.ColorLightRed),
+		2:                rgb(0x00ff00, termbox.ColorLightGreen),
+		3:                rgb(0xffff00, termbox.ColorLightYellow),
+		4:                rgb(0x00ffff, termbox.ColorLightCyan),
+		5:                rgb(0xff00ff, termbox.ColorLightMagenta),
+		6:                rgb(0x0080ff, termbox.ColorLightBlue),
+		7:                rgb(0xffffff, termbox.ColorWhite),
+		GarbageCellColor: rgb(0xa0a0a0, termbox.ColorLightGray),
+	}
+	// Colors told apart with the common kinds of color blindness by
+	// Okabe and Ito and by Paul Tol. Basic red and green look alike to
+	// most color blind players, so the basic colors of Z and S differ
+	// in lightness instead.
+	okabeItoPalette = palette{
+		1:                rgb(0xd55e00, termbox.ColorLightRed),
+		2:                rgb(0x009e73, termbox.ColorBlue),
+		3:                rgb(0xf0e442, termbox.ColorLightYellow),
+		4:                rgb(0x56b4e9, termbox.ColorLightCyan),
+		5:                rgb(0xcc79a7, termbox.ColorMagenta),
+		6:                rgb(0x0072b2, termbox.ColorLightBlue),
+		7:                rgb(0xe69f00, termbox.ColorYellow),
+		GarbageCellColor: rgb(0x999999, termbox.ColorDarkGray),
+	}
+	tolPalette = palette{
+		1:                rgb(0xee6677, termbox.ColorLightRed),
+		2:                rgb(0x228833, termbox.ColorBlue),
+		3:                rgb(0xccbb44, termbox.ColorYellow),
+		4:                rgb(0x66ccee, termbox.ColorLightCyan),
+		5:                rgb(0xaa3377, termbox.ColorMagenta),
+		6:                rgb(0x4477aa, termbox.ColorLightBlue),
+		7:                rgb(0xbbbbbb, termbox.ColorLightGray),
+		GarbageCellColor: rgb(0x555555, termbox.ColorDarkGray),
+	}
+	pastelPalette = palette{
+		1:                rgb(0xff9aa2, termbox.ColorLightRed),
+		2:                rgb(0xb5ead7, termbox.ColorLightGreen),
+		3:                rgb(0xfff5ba, termbox.ColorLightYellow),
+		4:                rgb(0xa0e7e5, termbox.ColorLightCyan),
+		5:                rgb(0xcdb4db, termbox.ColorLightMagenta),
+		6:                rgb(0xa2d2ff, termbox.ColorLightBlue),
+		7:                rgb(0xffdac1, termbox.ColorWhite),
+		GarbageCellColor: rgb(0x6c6c6c, termbox.ColorDarkGray),
+	}
+
+	palettes = map[string]palette{
+		"classic":       classicPalette,
+		"guideline":     guidelinePalette,
+		"monochrome":    monochromePalette,
+		"high-contrast": highContrastPalette,
+		"pastel":        pastelPalette,
+		"okabe-ito":     okabeItoPalette,
+		"tol":           tolPalette,
+	}
+
+	// ThemeNames are names of the themes in the order they are offered
+	// in the settings.
+	ThemeNames = []string{
+		"classic", "guideline", "monochrome", "high-contrast", "pastel",
+		"okabe-ito", "tol",
+	}
+)
+
+// Glyphs tell shapes and garbage apart without colors. They fill the
+// cells of fields with the letters of shapes when glyphs are on.
+var Glyphs = map[uint16][FieldXScale]rune{
+	1:                {'Z', 'Z'},
+	2:                {'S', 'S'},
+	3:                {'O', 'O'},
+	4:                {'I', 'I'},
+	5:                {'T', 'T'},
+	6:                {'J', 'J'},
+	7:                {'L', 'L'},
+	GarbageCellColor: {BlockChar, BlockChar},
+}
+
+// basicNames are names of basic colors in custom theme colors.
+var basicNames = map[string]termbox.Attribute{
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+	"gray":    termbox.ColorDarkGray,
+}
+
+// parseColors returns colors of the palette replaced by the ones in
+// colors, a list of shape names or garbage with a color separated by
+// commas, e.g. "I=#00f0f0, garbage=gray". A color is #rrggbb or a name
+// of a basic color.
+func (p palette) parseColors(colors string) (palette, error) {
+	custom := make(palette)
+	for color, c := range p {
+		custom[color] = c
+	}
+
+	for _, item := range strings.Split(colors, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("expected name=color in colors, got %q", item)
+		}
+		name, value := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+
+		color, ok := uint16(0), false
+		if name == "garbage" {
+			color, ok = GarbageCellColor, true
+		}
+		for _, shape := range Shapes {
+			if shape.name == name {
+				color, ok = shape.color, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown shape %q in colors", name)
+		}
+
+		if attr, ok := basicNames[value]; ok {
+			custom[color] = basic(attr)
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 16, 24)
+		if err != nil || len(value) != 7 || value[0] != '#' {
+			return nil, fmt.Errorf("invalid color %q of %s", value, name)
+		}
+		custom[color] = rgb(int(v), nearestBasic(int(v)))
+	}
+	return custom, nil
+}
+
+// Theme maps colors of shapes and garbage cells to terminal colors.
+type Theme map[uint16]termbox.Attribute
+
+var classicTheme = newTheme(classicPalette, ColorsBasic)
+
+// newTheme returns the theme showing colors of p in terminals of mode.
+func newTheme(p palette, mode ColorMode) Theme {
+	theme := Theme{0: termbox.ColorDefault}
+	for color, c := range p {
+		theme[color] = c.attribute(mode)
+	}
+	return theme
+}
+
+// findPalette returns the palette of the theme named name, DefaultTheme
+// if it is empty, with colors replaced as in parseColors.
+func findPalette(name, colors string) (palette, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	p, ok := palettes[name]
+	if !ok {
+		return nil, UnknownThemeError
+	}
+	return p.parseColors(colors)
+}
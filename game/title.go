@@ -0,0 +1,123 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+
+	"github.com/CatWantsMeow/gtetris/highscores"
+)
+
+// Choices of the title menu other than names of game modes to play.
+const (
+	TitleResume   = "resume"
+	TitleScores   = "scores"
+	TitleSettings = "settings"
+	TitleControls = "controls"
+	TitleQuit     = "quit"
+)
+
+const (
+	TitleMenuTitle   = "Main menu"
+	TitlePlayItem    = "Play %s"
+	TitleResumeItem  = "Resume saved game"
+	ScoresScreenHelp = "Mode: left/right   Back: esc"
+)
+
+var titleItems = map[string]string{
+	TitleScores:   "High scores",
+	TitleSettings: "Settings",
+	TitleControls: "Controls",
+	TitleQuit:     "Quit",
+}
+
+// TitleMenu shows the title menu on an initialized term with the choice
+// selected preselected and returns the chosen one. It offers to resume
+// the saved game if resume is set.
+func TitleMenu(term Terminal, resume bool, selected string) string {
+	var choices, items []string
+	if resume {
+		choices = append(choices, TitleResume)
+		items = append(items, TitleResumeItem)
+	}
+	for _, name := range ModeNames() {
+		choices = append(choices, name)
+		items = append(items, fmt.Sprintf(TitlePlayItem, name))
+	}
+	for _, choice := range []string{TitleScores, TitleSettings, TitleControls, TitleQuit} {
+		choices = append(choices, choice)
+		items = append(items, titleItems[choice])
+	}
+
+	menu := &Menu{Title: TitleMenuTitle, Items: items}
+	for i, choice := range choices {
+		if choice == selected {
+			menu.Selected = i
+		}
+	}
+	i := menu.Choose(term)
+	if i < 0 {
+		return TitleQuit
+	}
+	return choices[i]
+}
+
+// scoreModes returns names of the modes keeping high scores.
+func scoreModes() []string {
+	var names []string
+	for _, mode := range modes {
+		if !mode.Versus {
+			names = append(names, mode.Name)
+		}
+	}
+	return names
+}
+
+// ShowScores shows the high score table in path on an initialized term
+// starting with mode until the player goes back.
+func ShowScores(term Terminal, path string, mode string) error {
+	table, err := highscores.Load(path)
+	if err != nil {
+		return err
+	}
+
+	names := scoreModes()
+	current := 0
+	for i, name := range names {
+		if name == mode {
+			current = i
+		}
+	}
+
+	for {
+		mode := names[current]
+		lines := []string{fmt.Sprintf(ScoresHeader, mode), ""}
+		entries := table.Top(mode)
+		if len(entries) == 0 {
+			lines = append(lines, ScoresEmpty)
+		} else {
+			lines = append(lines, ScoresTableHeader)
+		}
+		for i, e := range entries {
+			lines = append(lines, scoresRow(i+1, e))
+		}
+		lines = append(lines, "", ScoresScreenHelp)
+		drawCentered(term, lines, map[int]termbox.Attribute{0: MenuTitleColor})
+
+		e := term.PollEvent()
+		switch {
+		case e.Type == termbox.EventError:
+			return nil
+		case e.Type == termbox.EventMouse && e.Key == termbox.MouseLeft && e.Mod&termbox.ModMotion == 0:
+			current = (current + 1) % len(names)
+		case e.Type != termbox.EventKey:
+		case e.Key == termbox.KeyArrowLeft || e.Ch == 'h':
+			current = (current + len(names) - 1) % len(names)
+		case e.Key == termbox.KeyArrowRight || e.Ch == 'l':
+			current = (current + 1) % len(names)
+		case e.Key == termbox.KeyEsc || e.Key == termbox.KeyEnter ||
+			e.Key == termbox.KeyCtrlC || e.Key == termbox.KeyCtrlD:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package highscores keeps the best local scores of every mode in a
+// JSON file.
+package highscores
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	MaxEntries    = 10
+	MaxNameLength = 12
+)
+
+type Entry struct {
+	Name     string    `json:"name"`
+	Score    int       `json:"score"`
+	Lines    int       `json:"lines"`
+	Level    string    `json:"level"`
+	Duration float64   `json:"duration"`
+	Date     time.Time `json:"date"`
+}
+
+// Table is the MaxEntries best scores of every mode, best first.
+type Table struct {
+	Modes map[string][]*Entry `json:"modes"`
+}
+
+// Rank returns the place score would take in mode or 0 if it isn't
+// good enough. Earlier scores win ties.
+func (t *Table) Rank(mode string, score int) int {
+	if score <= 0 {
+		return 0
+	}
+	entries := t.Modes[mode]
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].Score < score
+	})
+	if i >= MaxEntries {
+		return 0
+	}
+	return i + 1
+}
+
+// Add adds e to mode and returns its rank or 0 if it didn't qualify.
+func (t *Table) Add(mode string, e *Entry) int {
+	rank := t.Rank(mode, e.Score)
+	if rank == 0 {
+		return 0
+	}
+
+	entries := t.Modes[mode]
+	entries = append(entries, nil)
+	copy(entries[rank:], entries[rank-1:])
+	entries[rank-1] = e
+	if len(entries) > MaxEntries {
+		entries = entries[:MaxEntries]
+	}
+	t.Modes[mode] = entries
+	return rank
+}
+
+func (t *Table) Top(mode string) []*Entry {
+	return t.Modes[mode]
+}
+
+// Save writes the table to a temporary file first so a crash never
+// leaves a truncated file behind.
+func (t *Table) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// Load reads the table at path, which is empty if there's no file yet.
+func Load(path string) (*Table, error) {
+	t := &Table{Modes: make(map[string][]*Entry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, t)
+	if err != nil {
+		return nil, err
+	}
+	if t.Modes == nil {
+		t.Modes = make(map[string][]*Entry)
+	}
+	return t, nil
+}
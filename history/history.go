@@ -0,0 +1,82 @@
+// Package history keeps a record of every finished local game in a
+// JSON lines file and summarizes it into lifetime statistics.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Game is a finished game. Clears counts singles, doubles, triples and
+// tetrises.
+type Game struct {
+	Date            time.Time      `json:"date"`
+	Mode            string         `json:"mode"`
+	Duration        float64        `json:"duration"`
+	Score           int            `json:"score"`
+	Lines           int            `json:"lines"`
+	Blocks          int            `json:"blocks"`
+	Level           string         `json:"level"`
+	Pieces          map[string]int `json:"pieces"`
+	Clears          [4]int         `json:"clears"`
+	Inputs          int            `json:"inputs"`
+	GarbageSent     int            `json:"garbage_sent,omitempty"`
+	GarbageReceived int            `json:"garbage_received,omitempty"`
+}
+
+func (g *Game) PPS() float64 {
+	if g.Duration == 0 {
+		return 0
+	}
+	return float64(g.Blocks) / g.Duration
+}
+
+// Append adds g to the history at path. Games are appended as single
+// lines so concurrent games never overwrite each other.
+func Append(path string, g *Game) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads games at path, oldest first. Lines which can't be read,
+// e.g. one cut short by a crash, are skipped.
+func Load(path string) ([]*Game, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var games []*Game
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var g Game
+		if json.Unmarshal(scanner.Bytes(), &g) == nil {
+			games = append(games, &g)
+		}
+	}
+	return games, scanner.Err()
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// RecentGames is the number of last games trends are computed over.
+const RecentGames = 10
+
+var (
+	clearNames = []string{"Singles", "Doubles", "Triples", "Tetrises"}
+	shapeNames = []string{"I", "O", "T", "S", "Z", "J", "L"}
+)
+
+// Summary is lifetime statistics of games of a mode.
+type Summary struct {
+	Mode     string
+	Games    int
+	Duration float64
+	Score    int
+	Lines    int
+	Blocks   int
+	Inputs   int
+	Pieces   map[string]int
+	Clears   [4]int
+
+	BestScore int
+	BestLines int
+	BestPPS   float64
+
+	// Averages over the last RecentGames games.
+	RecentScore float64
+	RecentPPS   float64
+}
+
+func (s *Summary) AverageScore() float64 {
+	if s.Games == 0 {
+		return 0
+	}
+	return float64(s.Score) / float64(s.Games)
+}
+
+func (s *Summary) PPS() float64 {
+	if s.Duration == 0 {
+		return 0
+	}
+	return float64(s.Blocks) / s.Duration
+}
+
+// Summarize summarizes games of mode, of all modes if mode is empty.
+func Summarize(games []*Game, mode string) *Summary {
+	s := &Summary{Mode: mode, Pieces: make(map[string]int)}
+	var recent []*Game
+	for _, g := range games {
+		if mode != "" && g.Mode != mode {
+			continue
+		}
+		recent = append(recent, g)
+
+		s.Games++
+		s.Duration += g.Duration
+		s.Score += g.Score
+		s.Lines += g.Lines
+		s.Blocks += g.Blocks
+		s.Inputs += g.Inputs
+		for shape, n := range g.Pieces {
+			s.Pieces[shape] += n
+		}
+		for i, n := range g.Clears {
+			s.Clears[i] += n
+		}
+
+		if g.Score > s.BestScore {
+			s.BestScore = g.Score
+		}
+		if g.Lines > s.BestLines {
+			s.BestLines = g.Lines
+		}
+		if g.PPS() > s.BestPPS {
+			s.BestPPS = g.PPS()
+		}
+	}
+
+	if len(recent) > RecentGames {
+		recent = recent[len(recent)-RecentGames:]
+	}
+	for _, g := range recent {
+		s.RecentScore += float64(g.Score) / float64(len(recent))
+		s.RecentPPS += g.PPS() / float64(len(recent))
+	}
+	return s
+}
+
+// Modes returns modes games were played in.
+func Modes(games []*Game) []string {
+	seen := make(map[string]bool)
+	var modes []string
+	for _, g := range games {
+		if !seen[g.Mode] {
+			seen[g.Mode] = true
+			modes = append(modes, g.Mode)
+		}
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+func trend(recent, overall float64) string {
+	switch {
+	case recent > overall*1.05:
+		return "up"
+	case recent < overall*0.95:
+		return "down"
+	}
+	return "steady"
+}
+
+func bar(n, total, width int) string {
+	if total == 0 {
+		return ""
+	}
+	return strings.Repeat("#", n*width/total)
+}
+
+// Report writes s as text.
+func (s *Summary) Report(w io.Writer) {
+	mode := s.Mode
+	if mode == "" {
+		mode = "all modes"
+	}
+	hours := int(s.Duration) / 3600
+	minutes := int(s.Duration) % 3600 / 60
+
+	fmt.Fprintf(w, "%s: %d games, %dh%02dm played\n", mode, s.Games, hours, minutes)
+	if s.Games == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  Best score:     %d\n", s.BestScore)
+	fmt.Fprintf(w, "  Best lines:     %d\n", s.BestLines)
+	fmt.Fprintf(w, "  Best PPS:       %.2f\n", s.BestPPS)
+	fmt.Fprintf(w, "  Average score:  %.0f, last %d games %.0f (%s)\n",
+		s.AverageScore(), RecentGames, s.RecentScore, trend(s.RecentScore, s.AverageScore()))
+	fmt.Fprintf(w, "  Average PPS:    %.2f, last %d games %.2f (%s)\n",
+		s.PPS(), RecentGames, s.RecentPPS, trend(s.RecentPPS, s.PPS()))
+	fmt.Fprintf(w, "  Lines:          %d\n", s.Lines)
+	fmt.Fprintf(w, "  Pieces:         %d\n", s.Blocks)
+
+	clears := 0
+	for _, n := range s.Clears {
+		clears += n
+	}
+	fmt.Fprintf(w, "  Line clears:\n")
+	for i, n := range s.Clears {
+		percent := 0.0
+		if clears > 0 {
+			percent = 100 * float64(n) / float64(clears)
+		}
+		line := fmt.Sprintf("    %-9s %6d %5.1f%% %s", clearNames[i], n, percent, bar(n, clears, 20))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+
+	fmt.Fprintf(w, "  Pieces by shape:\n")
+	for _, shape := range shapeNames {
+		n := s.Pieces[shape]
+		line := fmt.Sprintf("    %-9s %6d %s", shape, n, bar(n, s.Blocks, 20))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
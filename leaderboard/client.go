@@ -0,0 +1,100 @@
+package leaderboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const ClientTimeout = 10 * time.Second
+
+// Client talks to a leaderboard server at URL, e.g. http://host:7780.
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+func (c *Client) do(req *http.Request, v interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("leaderboard: %s", resp.Status)
+	}
+	if b, ok := v.(*[]byte); ok {
+		*b, err = io.ReadAll(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *Client) Submit(score *Score) (*Score, error) {
+	data, err := json.Marshal(score)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url+"/scores", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var stored Score
+	err = c.do(req, &stored)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (c *Client) Scores(q Query) ([]*Score, error) {
+	params := url.Values{}
+	if q.Mode != "" {
+		params.Set("mode", q.Mode)
+	}
+	if q.Seed != 0 {
+		params.Set("seed", fmt.Sprint(q.Seed))
+	}
+	if q.Limit != 0 {
+		params.Set("limit", fmt.Sprint(q.Limit))
+	}
+	req, err := http.NewRequest(http.MethodGet, c.url+"/scores?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var scores []*Score
+	err = c.do(req, &scores)
+	return scores, err
+}
+
+func (c *Client) Replay(id int) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/scores/%d/replay", c.url, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = c.do(req, &data)
+	return data, err
+}
+
+func NewClient(url string) *Client {
+	return &Client{
+		url:  strings.TrimRight(url, "/"),
+		http: &http.Client{Timeout: ClientTimeout},
+	}
+}
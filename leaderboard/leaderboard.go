@@ -0,0 +1,80 @@
+// Package leaderboard implements an HTTP/JSON service keeping scores of
+// finished games per mode and seed, and a client to submit them.
+//
+// The API is:
+//
+//	POST /scores                  submit a score, optionally with a replay
+//	                              played again to verify the score
+//	GET  /scores?mode=&seed=&limit= best scores, optionally filtered
+//	GET  /scores/{id}             a single score
+//	GET  /scores/{id}/replay      the replay attached to a score
+package leaderboard
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAddr  = ":7780"
+	DefaultLimit = 10
+	MaxLimit     = 100
+
+	MaxNameLength = 32
+	MaxReplaySize = 1 << 20
+)
+
+var (
+	NotFoundError     = errors.New("score not found")
+	NoReplayError     = errors.New("score has no replay")
+	InvalidScoreError = errors.New("invalid score")
+	MismatchError     = errors.New("replay doesn't match the score")
+)
+
+// Verifier plays the replay attached to a score with the seed and rules
+// it recorded and returns an error unless it ends with the score.
+type Verifier func(score *Score) error
+
+// Score is a result of a finished game. Replay is only set when a score
+// is submitted and is served separately afterwards.
+type Score struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Mode      string    `json:"mode"`
+	Seed      int64     `json:"seed"`
+	Score     int       `json:"score"`
+	Lines     int       `json:"lines"`
+	Level     string    `json:"level"`
+	Blocks    int       `json:"blocks"`
+	Elapsed   float64   `json:"elapsed"`
+	Date      time.Time `json:"date"`
+	HasReplay bool      `json:"has_replay"`
+	Replay    []byte    `json:"replay,omitempty"`
+}
+
+func (s *Score) validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	switch {
+	case s.Name == "" || len(s.Name) > MaxNameLength:
+		return InvalidScoreError
+	case s.Mode == "":
+		return InvalidScoreError
+	case s.Score < 0 || s.Lines < 0 || s.Blocks < 0 || s.Elapsed < 0:
+		return InvalidScoreError
+	case len(s.Replay) > MaxReplaySize:
+		return InvalidScoreError
+	}
+	return nil
+}
+
+// Query selects scores of a mode and seed, any if empty or zero.
+type Query struct {
+	Mode  string
+	Seed  int64
+	Limit int
+}
+
+func (q *Query) matches(s *Score) bool {
+	return (q.Mode == "" || q.Mode == s.Mode) && (q.Seed == 0 || q.Seed == s.Seed)
+}
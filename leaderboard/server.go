@@ -0,0 +1,156 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server serves the API on top of a Store, rejecting scores whose
+// replays don't pass verify.
+type Server struct {
+	store  *Store
+	verify Verifier
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var score Score
+	body := http.MaxBytesReader(w, r.Body, 2*MaxReplaySize)
+	err := json.NewDecoder(body).Decode(&score)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = score.validate()
+	if err == nil && len(score.Replay) > 0 && s.verify != nil {
+		err = s.verify(&score)
+		if err != nil {
+			log.Warning("Rejected score %d of %s: %s.", score.Score, score.Name, err)
+		}
+	}
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stored, err := s.store.Add(&score)
+	if err == InvalidScoreError {
+		s.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.Error("Failed to store score: %s.", err)
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	log.Info("%s scored %d in %s mode.", stored.Name, stored.Score, stored.Mode)
+	s.writeJSON(w, http.StatusCreated, stored)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := Query{Mode: params.Get("mode")}
+
+	var err error
+	if seed := params.Get("seed"); seed != "" {
+		q.Seed, err = strconv.ParseInt(seed, 10, 64)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit > MaxLimit {
+			q.Limit = MaxLimit
+		}
+	}
+
+	scores := s.store.Find(q)
+	if scores == nil {
+		scores = []*Score{}
+	}
+	s.writeJSON(w, http.StatusOK, scores)
+}
+
+func (s *Server) get(w http.ResponseWriter, id int, replay bool) {
+	if !replay {
+		score, err := s.store.Get(id)
+		if err != nil {
+			s.writeError(w, http.StatusNotFound, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, score)
+		return
+	}
+
+	data, err := s.store.Replay(id)
+	if err == NotFoundError || err == NoReplayError {
+		s.writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "scores" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.submit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.list(w, r)
+	case len(parts) > 1 && r.Method == http.MethodGet:
+		id, err := strconv.Atoi(parts[1])
+		if err != nil || (len(parts) == 3 && parts[2] != "replay") {
+			http.NotFound(w, r)
+			return
+		}
+		s.get(w, id, len(parts) == 3)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func NewServer(store *Store, verify Verifier) *Server {
+	return &Server{store: store, verify: verify}
+}
+
+// ListenAndServe serves scores stored in dir on addr, verifying their
+// replays with verify.
+func ListenAndServe(addr, dir string, verify Verifier) error {
+	store, err := NewStore(dir)
+	if err != nil {
+		return err
+	}
+	return http.ListenAndServe(addr, NewServer(store, verify))
+}
@@ -0,0 +1,159 @@
+package leaderboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ScoresFile = "scores.json"
+	ReplaysDir = "replays"
+)
+
+// Store keeps scores in a JSON file and replays in separate files of a
+// data directory.
+type Store struct {
+	dir    string
+	scores []*Score
+	nextID int
+	mu     sync.Mutex
+}
+
+func (s *Store) replayPath(id int) string {
+	return filepath.Join(s.dir, ReplaysDir, fmt.Sprintf("%d.gtr", id))
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, ScoresFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, &s.scores)
+	if err != nil {
+		return err
+	}
+	for _, score := range s.scores {
+		if score.ID >= s.nextID {
+			s.nextID = score.ID + 1
+		}
+	}
+	return nil
+}
+
+// save writes scores to a temporary file first so a crash never leaves
+// a truncated file behind.
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.scores, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, ScoresFile)
+	err = os.WriteFile(path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *Store) Add(score *Score) (*Score, error) {
+	err := score.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *score
+	stored.ID = s.nextID
+	stored.Date = time.Now().UTC()
+	stored.HasReplay = len(score.Replay) > 0
+	stored.Replay = nil
+
+	if stored.HasReplay {
+		err = os.WriteFile(s.replayPath(stored.ID), score.Replay, 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.scores = append(s.scores, &stored)
+	err = s.save()
+	if err != nil {
+		s.scores = s.scores[:len(s.scores)-1]
+		return nil, err
+	}
+	s.nextID++
+	return &stored, nil
+}
+
+// Find returns the best scores matching q, earlier ones first on ties.
+func (s *Store) Find(q Query) []*Score {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var scores []*Score
+	for _, score := range s.scores {
+		if q.matches(score) {
+			scores = append(scores, score)
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Score > scores[j].Score
+	})
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if len(scores) > q.Limit {
+		scores = scores[:q.Limit]
+	}
+	return scores
+}
+
+func (s *Store) Get(id int) (*Score, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, score := range s.scores {
+		if score.ID == id {
+			return score, nil
+		}
+	}
+	return nil, NotFoundError
+}
+
+func (s *Store) Replay(id int) ([]byte, error) {
+	score, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !score.HasReplay {
+		return nil, NoReplayError
+	}
+	return os.ReadFile(s.replayPath(id))
+}
+
+func NewStore(dir string) (*Store, error) {
+	err := os.MkdirAll(filepath.Join(dir, ReplaysDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{dir: dir}
+	err = s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
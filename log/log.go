@@ -2,7 +2,9 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -11,20 +13,44 @@ const (
 	LevelInfo    = "I"
 	LevelWarning = "W"
 	LevelError   = "E"
+
+	MaxEntries = 1000
 )
 
 type Logger struct {
 	entries []string
+	output  io.Writer
+	mu      sync.Mutex
 }
 
 func (l *Logger) Log(level string, msg string, args ...interface{}) {
 	msg = fmt.Sprintf(msg, args...)
 	timestamp := time.Now().Format("15:04:05.0000")
 	entry := level + " [" + timestamp + "] " + msg
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) >= MaxEntries {
+		l.entries = append(l.entries[:0], l.entries[MaxEntries/2:]...)
+	}
 	l.entries = append(l.entries, entry)
+	if l.output != nil && level != LevelDebug {
+		fmt.Fprintln(l.output, entry)
+	}
+}
+
+// SetOutput makes the logger also write entries other than debug ones
+// to w, e.g. for servers running without a screen.
+func (l *Logger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.output = w
 }
 
 func (l *Logger) String(tail int, width int) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	start := len(l.entries) - tail
 	if start < 0 {
 		start = 0
@@ -63,6 +89,10 @@ func Error(msg string, args ...interface{}) {
 	logger.Log(LevelError, msg, args...)
 }
 
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
 func String(tail, width int) string {
 	return logger.String(tail, width)
 }
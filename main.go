@@ -2,14 +2,456 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
 
+	"github.com/CatWantsMeow/gtetris/config"
 	"github.com/CatWantsMeow/gtetris/game"
+	"github.com/CatWantsMeow/gtetris/highscores"
+	"github.com/CatWantsMeow/gtetris/history"
+	"github.com/CatWantsMeow/gtetris/netplay"
+	"github.com/CatWantsMeow/gtetris/profile"
 )
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, ""+
+		"Usage:\n"+
+		"  %[1]s [flags]                       choose a game from the title menu\n"+
+		"  %[1]s [flags] command [command flags] [arguments]\n"+
+		"\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-24s %s\n", cmd.name+" "+cmd.args, cmd.summary)
+	}
+	fmt.Fprintf(out, ""+
+		"\nRun %[1]s command -h for help on a command.\n"+
+		"\nOptions are read from %[2]s, GTETRIS_* environment variables\n"+
+		"such as GTETRIS_MIN_PLAYERS and flags, each overriding the ones before.\n"+
+		"\nExit codes: %[3]d on success, %[4]d on errors, %[5]d on wrong flags or arguments\n"+
+		"and %[6]d if a replay doesn't match.\n"+
+		"\nFlags:\n", os.Args[0], config.Path(), ExitOK, ExitError, ExitUsage, ExitMismatch)
+	flag.PrintDefaults()
+}
+
+func connect(cmd string, addr string) (*netplay.Conn, error) {
+	switch cmd {
+	case "serve":
+		if addr == "" {
+			addr = netplay.DefaultAddr
+		}
+		fmt.Printf("Waiting for opponent on %s...\n", addr)
+		return netplay.Listen(addr)
+	case "join", "watch":
+		if addr == "" {
+			return nil, fmt.Errorf("%s requires an address", cmd)
+		}
+		fmt.Printf("Connecting to %s...\n", addr)
+		return netplay.Dial(addr)
+	}
+	return nil, fmt.Errorf("unknown command %q", cmd)
+}
+
+// printScores prints high scores of mode or of every mode with scores.
+func printScores(p *profile.Profile, mode string) error {
+	table, err := highscores.Load(p.ScoresPath())
+	if err != nil {
+		return err
+	}
+
+	var modes []string
+	for name := range table.Modes {
+		if mode == "" || mode == name {
+			modes = append(modes, name)
+		}
+	}
+	sort.Strings(modes)
+	if len(modes) == 0 {
+		fmt.Println("No high scores yet.")
+	}
+
+	for i, name := range modes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s\n", name)
+		for j, e := range table.Top(name) {
+			fmt.Printf(
+				"%2d. %-12s %7d %5d lines  level %-2s %4.0fs  %s\n",
+				j+1, e.Name, e.Score, e.Lines, e.Level, e.Duration,
+				e.Date.Format("2006-01-02 15:04"),
+			)
+		}
+	}
+	return nil
+}
+
+// printStats prints lifetime statistics of mode or of every mode and
+// all of them together.
+func printStats(p *profile.Profile, mode string) error {
+	games, err := history.Load(p.HistoryPath())
+	if err != nil {
+		return err
+	}
+	if len(games) == 0 {
+		fmt.Println("No finished games yet.")
+		return nil
+	}
+
+	modes := history.Modes(games)
+	if mode != "" || len(modes) == 1 {
+		if mode == "" {
+			mode = modes[0]
+		}
+		history.Summarize(games, mode).Report(os.Stdout)
+		return nil
+	}
+	history.Summarize(games, "").Report(os.Stdout)
+	for _, mode := range modes {
+		fmt.Println()
+		history.Summarize(games, mode).Report(os.Stdout)
+	}
+	return nil
+}
+
+// loadProfile loads the profile named name, creating it if needed. If
+// name is empty and there are several profiles, the player is asked to
+// choose one when playing interactively, with the mouse too if mouse is
+// set. The returned profile is nil if nothing was chosen.
+func loadProfile(name string, interactive, mouse bool) (*profile.Profile, error) {
+	if name == "" {
+		name = profile.DefaultName
+		profiles := profile.List()
+		if interactive && len(profiles) > 1 {
+			var err error
+			name, err = game.ChooseProfile(profiles, profile.CheckName, mouse)
+			if err != nil || name == "" {
+				return nil, err
+			}
+		}
+	}
+
+	p, err := profile.Load(name)
+	if err == profile.NotFoundError {
+		fmt.Printf("Creating profile %s.\n", name)
+		return profile.Create(name)
+	}
+	return p, err
+}
+
+// applyProfile sets options of cfg from settings of p, which override
+// defaults but none of the other sources.
+func applyProfile(cfg *config.Config, p *profile.Profile) {
+	s := p.Settings
+	values := map[string]string{
+		"mode":     s.Mode,
+		"name":     s.Name,
+		"noanim":   strconv.FormatBool(!s.Animations),
+		"mouse":    strconv.FormatBool(s.Mouse),
+		"ghost":    strconv.FormatBool(s.Ghost),
+		"previews": strconv.Itoa(s.Previews),
+		"das":      strconv.Itoa(s.DAS),
+		"arr":      strconv.Itoa(s.ARR),
+		"theme":    s.Theme,
+		"glyphs":   strconv.FormatBool(s.Glyphs),
+		"layout":   s.Layout,
+		"sound":    strconv.FormatBool(s.Sound),
+	}
+	for name, value := range values {
+		// Older profiles lack some of the settings.
+		if value == "" || value == "0" && name == "previews" {
+			continue
+		}
+		cfg.Set(name, value, config.SourceProfile, p.Name)
+	}
+}
+
+// editKeys lets the player edit key bindings of p and saves them.
+func editKeys(p *profile.Profile, keys game.KeyMap, mouse bool) error {
+	keys, preset, err := game.KeySettings(keys, p.Settings.KeyPreset, mouse)
+	if err != nil || keys == nil {
+		return err
+	}
+	p.Settings.KeyPreset = preset
+	p.Settings.Keys = keys
+	return p.Save()
+}
+
+// applySettings applies settings changed in the settings screen from
+// old to s to the options of the next games and the profile. Only the
+// settings the player changed are saved, not the ones coming from flags,
+// the environment or the config file.
+func applySettings(p *profile.Profile, opts *game.Options, old, s game.Settings) {
+	if s.Ghost != old.Ghost {
+		p.Settings.Ghost = s.Ghost
+	}
+	if s.Previews != old.Previews {
+		p.Settings.Previews = s.Previews
+	}
+	if s.DAS != old.DAS {
+		p.Settings.DAS = s.DAS
+	}
+	if s.ARR != old.ARR {
+		p.Settings.ARR = s.ARR
+	}
+	if s.Theme != old.Theme {
+		p.Settings.Theme = s.Theme
+	}
+	if s.Glyphs != old.Glyphs {
+		p.Settings.Glyphs = s.Glyphs
+	}
+	if s.Layout != old.Layout {
+		p.Settings.Layout = s.Layout
+	}
+	if s.Sound != old.Sound {
+		p.Settings.Sound = s.Sound
+	}
+	if s.Animations != old.Animations {
+		p.Settings.Animations = s.Animations
+	}
+	if s.Mouse != old.Mouse {
+		p.Settings.Mouse = s.Mouse
+	}
+
+	opts.Ghost = s.Ghost
+	opts.Previews = s.Previews
+	opts.DAS = s.DAS
+	opts.ARR = s.ARR
+	opts.Theme = s.Theme
+	opts.Glyphs = s.Glyphs
+	opts.Layout = s.Layout
+	opts.Sound = s.Sound
+	opts.Animations = s.Animations
+	opts.Mouse = s.Mouse
+}
+
+// titleMenu shows the title menu and the screens chosen in it on term
+// until a game to play is chosen or the player quits.
+func titleMenu(term game.Terminal, p *profile.Profile, opts *game.Options, selected string) (string, error) {
+	for {
+		_, err := os.Stat(p.SavePath())
+		choice := game.TitleMenu(term, err == nil, selected)
+		switch choice {
+		case game.TitleScores:
+			err := game.ShowScores(term, p.ScoresPath(), opts.Mode)
+			if err != nil {
+				return "", err
+			}
+
+		case game.TitleSettings:
+			old := game.Settings{
+				Ghost:      opts.Ghost,
+				Previews:   opts.Previews,
+				DAS:        opts.DAS,
+				ARR:        opts.ARR,
+				Theme:      opts.Theme,
+				Glyphs:     opts.Glyphs,
+				Layout:     opts.Layout,
+				Sound:      opts.Sound,
+				Animations: opts.Animations,
+				Mouse:      opts.Mouse,
+			}
+			s := game.EditSettings(term, old)
+			applySettings(p, opts, old, s)
+			err := p.Save()
+			if err != nil {
+				return "", err
+			}
+
+		case game.TitleControls:
+			keys, preset := game.EditKeys(term, opts.Keys, p.Settings.KeyPreset)
+			if keys != nil {
+				opts.Keys = keys
+				p.Settings.KeyPreset = preset
+				p.Settings.Keys = keys
+				err := p.Save()
+				if err != nil {
+					return "", err
+				}
+			}
+
+		default:
+			return choice, nil
+		}
+		selected = choice
+	}
+}
+
+// title shows the title menu until the player quits and runs the games
+// chosen in it.
+func title(p *profile.Profile, opts game.Options) error {
+	term := game.NewTermboxTerminal()
+	selected := opts.Mode
+	for {
+		err := term.Init()
+		if err != nil {
+			return err
+		}
+		term.SetMouse(opts.Mouse)
+		choice, err := titleMenu(term, p, &opts, selected)
+		term.Close()
+		if err != nil || choice == game.TitleQuit {
+			return err
+		}
+
+		selected = choice
+		o := opts
+		if choice == game.TitleResume {
+			o.Resume = true
+		} else {
+			o.Mode = choice
+		}
+		err = game.Run(o)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lobby hosts battle royale rounds with the given number of simulated
+// players joined to it.
+func lobby(addr string, bots, minPlayers int, opts game.Options) error {
+	if addr == "" {
+		addr = netplay.DefaultLobbyAddr
+	}
+	l, err := netplay.NewLobby(addr, minPlayers)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	// Bots are dialed after the lobby starts accepting since joining
+	// requires a handshake.
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.Serve()
+	}()
+
+	fmt.Printf("Hosting battle royale lobby on %s with %d bots...\n", addr, bots)
+	for i := 1; i <= bots; i++ {
+		conn, err := netplay.Dial(l.Addr().String())
+		if err != nil {
+			return err
+		}
+
+		opts := opts
+		opts.Lobby = conn
+		opts.Name = fmt.Sprintf("bot%d", i)
+		opts.Bot = true
+		opts.Terminal = game.NewNullTerminal()
+		go game.Run(opts)
+	}
+	return <-errc
+}
+
 func main() {
-	debug := flag.Bool("debug", false, "Run game in debug mode.")
-	fast := flag.Bool("fast", false, "Speeds up game.")
-	flag.Parse()
+	os.Exit(run())
+}
+
+func run() int {
+	flag.Usage = usage
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitError
+	}
+
+	// Without a command the player chooses what to play from the title
+	// menu, unless streaming a game right away.
+	s := &session{cfg: cfg}
+	name := flag.Arg(0)
+	if name != "" {
+		s.cmd = findCommand(name)
+		if s.cmd == nil {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+			usage()
+			return ExitUsage
+		}
+		err := s.cmd.parse(s, flag.Args()[1:])
+		if err == flag.ErrHelp {
+			return ExitOK
+		}
+		if err != nil {
+			return ExitUsage
+		}
+	} else if cfg.Spectate != "" {
+		s.cmd = findCommand("play")
+	}
+
+	interactive := s.cmd == nil || s.cmd.name == "play"
+	s.prof, err = loadProfile(cfg.Profile, interactive, cfg.Mouse)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitError
+	}
+	if s.prof == nil {
+		return ExitOK
+	}
+	applyProfile(cfg, s.prof)
+	if cfg.Replays == "" {
+		cfg.Replays = s.prof.ReplayDir()
+	}
+	replays := cfg.Replays
+	if replays == config.NoReplays {
+		replays = ""
+	}
+
+	keys, err := game.LoadKeyMap(s.prof.Settings.KeyPreset, s.prof.Settings.Keys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Key bindings of profile %s: %s\n", s.prof.Name, err)
+		return ExitError
+	}
+	err = keys.ConflictError()
+	if err != nil && (s.cmd == nil || !s.cmd.anyKeys) {
+		fmt.Fprintf(os.Stderr, "Key bindings of profile %s: %s\n", s.prof.Name, err)
+		fmt.Fprintf(os.Stderr, "Fix them with %s keys.\n", os.Args[0])
+		return ExitError
+	}
+
+	s.opts = game.Options{
+		Debug:      cfg.Debug,
+		Fast:       cfg.Fast,
+		Animations: !cfg.NoAnim,
+		Mode:       cfg.Mode,
+		Name:       cfg.Name,
+		Seed:       cfg.Seed,
+		Keys:       keys,
+		Mouse:      cfg.Mouse,
+		Ghost:      cfg.Ghost,
+		Previews:   cfg.Previews,
+		DAS:        cfg.DAS,
+		ARR:        cfg.ARR,
+		Theme:      cfg.Theme,
+		Colors:     cfg.Colors,
+		ColorMode:  cfg.ColorMode,
+		Glyphs:     cfg.Glyphs,
+		Layout:     cfg.Layout,
+		Sound:      cfg.Sound,
+		Width:      cfg.Width,
+		Height:     cfg.Height,
+		StartLevel: cfg.StartLevel,
+		EntryDelay: cfg.EntryDelay,
+		ClearDelay: cfg.ClearDelay,
+
+		Leaderboard: cfg.Leaderboard,
+		ReplayDir:   replays,
+		SaveFile:    s.prof.SavePath(),
+		HighScores:  s.prof.ScoresPath(),
+		History:     s.prof.HistoryPath(),
+	}
 
-	game.Run(*debug, *fast)
+	if s.cmd == nil {
+		err = title(s.prof, s.opts)
+	} else {
+		err = s.cmd.run(s)
+	}
+	switch {
+	case err == MismatchError:
+		return ExitMismatch
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return ExitError
+	}
+	return ExitOK
 }
@@ -0,0 +1,353 @@
+package netplay
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+const (
+	DefaultLobbyAddr = ":7778"
+	MaxPlayers       = 16
+	MinPlayers       = 2
+
+	StrategyRandom    = "random"
+	StrategyAttackers = "attackers"
+	StrategyKO        = "ko"
+
+	// Rounds start StartDelay after enough players joined or after the
+	// previous round ended. Players are sent to everyone every
+	// RosterDelay.
+	StartDelay  = 10 * time.Second
+	RosterDelay = 200 * time.Millisecond
+)
+
+var (
+	Strategies = []string{StrategyRandom, StrategyAttackers, StrategyKO}
+
+	// Percents of the sent garbage for 0, 1, 2, 4 and 8 or more badges.
+	badgeBonus = []int{100, 125, 150, 150, 175, 175, 175, 175, 200}
+)
+
+type lobbyPlayer struct {
+	Player
+	conn     *Conn
+	strategy string
+	attacker int
+}
+
+// delivery is a message to a player sent once the lobby is unlocked.
+type delivery struct {
+	conn *Conn
+	m    *Message
+}
+
+// Lobby hosts battle royale rounds for up to MaxPlayers players. Lines
+// sent by a player are routed to an opponent chosen by the player's
+// targeting strategy, and knocking a player out earns its badges which
+// increase garbage sent afterwards.
+type Lobby struct {
+	listener   net.Listener
+	players    map[int]*lobbyPlayer
+	nextID     int
+	playing    bool
+	startAt    time.Time
+	minPlayers int
+	mu         sync.Mutex
+
+	// Messages queued under mu are sent under sendMu once mu is
+	// released, in order, so slow players don't hold up the others.
+	outbox []delivery
+	sendMu sync.Mutex
+}
+
+// send queues m to conn until the lobby is unlocked.
+func (l *Lobby) send(conn *Conn, m *Message) {
+	l.outbox = append(l.outbox, delivery{conn, m})
+}
+
+// unlock unlocks the lobby and sends the queued messages. Players whose
+// outgoing buffer is full are disconnected by Send.
+func (l *Lobby) unlock() {
+	outbox := l.outbox
+	l.outbox = nil
+	l.sendMu.Lock()
+	defer l.sendMu.Unlock()
+	l.mu.Unlock()
+
+	for _, d := range outbox {
+		d.conn.Send(d.m)
+	}
+}
+
+func stackHeight(b *Board) int {
+	if b == nil {
+		return 0
+	}
+	for i, color := range b.Cells {
+		if color != 0 {
+			return b.Height - i/b.Width
+		}
+	}
+	return 0
+}
+
+func (l *Lobby) alive() (players []*lobbyPlayer) {
+	for _, p := range l.players {
+		if p.Alive {
+			players = append(players, p)
+		}
+	}
+	return players
+}
+
+func (l *Lobby) pickTarget(sender *lobbyPlayer) *lobbyPlayer {
+	var candidates, attackers []*lobbyPlayer
+	for _, p := range l.alive() {
+		if p == sender {
+			continue
+		}
+		candidates = append(candidates, p)
+		if p.Target == sender.ID {
+			attackers = append(attackers, p)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch sender.strategy {
+	case StrategyAttackers:
+		if len(attackers) > 0 {
+			return attackers[rand.Intn(len(attackers))]
+		}
+	case StrategyKO:
+		target := candidates[0]
+		for _, p := range candidates[1:] {
+			if stackHeight(p.Board) > stackHeight(target.Board) {
+				target = p
+			}
+		}
+		return target
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+func (l *Lobby) attack(sender *lobbyPlayer, lines int) {
+	if !l.playing || !sender.Alive {
+		return
+	}
+
+	bonus := badgeBonus[len(badgeBonus)-1]
+	if sender.Badges < len(badgeBonus) {
+		bonus = badgeBonus[sender.Badges]
+	}
+	lines = lines * bonus / 100
+
+	target := l.pickTarget(sender)
+	if target == nil {
+		return
+	}
+	sender.Target = target.ID
+	target.attacker = sender.ID
+	l.send(target.conn, &Message{Type: MessageGarbage, Lines: lines})
+}
+
+func (l *Lobby) knockOut(p *lobbyPlayer) {
+	if !l.playing || !p.Alive {
+		return
+	}
+
+	p.Place = len(l.alive())
+	p.Alive = false
+	l.send(p.conn, &Message{Type: MessageResult, Place: p.Place})
+	log.Info("%s is knocked out, place %d.", p.Name, p.Place)
+
+	if attacker, ok := l.players[p.attacker]; ok && attacker.Alive {
+		attacker.Badges += 1 + p.Badges
+		log.Info("%s knocked out %s.", attacker.Name, p.Name)
+	}
+
+	alive := l.alive()
+	if len(alive) <= 1 {
+		for _, winner := range alive {
+			winner.Place = 1
+			winner.Alive = false
+			l.send(winner.conn, &Message{Type: MessageResult, Place: 1})
+			log.Info("%s won the round.", winner.Name)
+		}
+		l.playing = false
+		l.startAt = time.Now().Add(StartDelay)
+	}
+}
+
+func (l *Lobby) start() {
+	l.playing = true
+	for _, p := range l.players {
+		p.Alive = true
+		p.Badges = 0
+		p.Place = 0
+		p.Target = 0
+		p.attacker = 0
+		p.Board = nil
+		l.send(p.conn, &Message{Type: MessageStart})
+	}
+	log.Info("Started round with %d players.", len(l.players))
+}
+
+func (l *Lobby) roster() []*Player {
+	players := make([]*Player, 0, len(l.players))
+	for _, p := range l.players {
+		player := p.Player
+		players = append(players, &player)
+	}
+	return players
+}
+
+// run starts rounds and sends players to everyone until the lobby is
+// closed.
+func (l *Lobby) run(done chan struct{}) {
+	ticker := time.NewTicker(RosterDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		if !l.playing && len(l.players) >= l.minPlayers && time.Now().After(l.startAt) {
+			l.start()
+		}
+		m := &Message{Type: MessagePlayers, Players: l.roster()}
+		conns := make([]*Conn, 0, len(l.players))
+		for _, p := range l.players {
+			conns = append(conns, p.conn)
+		}
+		l.unlock()
+
+		// Players get the next roster if they miss this one.
+		for _, conn := range conns {
+			conn.TrySend(m)
+		}
+	}
+}
+
+func (l *Lobby) join(conn *Conn) *lobbyPlayer {
+	m, err := conn.Receive()
+	if err != nil || m.Type != MessageJoin {
+		conn.Close()
+		return nil
+	}
+
+	l.mu.Lock()
+	if len(l.players) >= MaxPlayers {
+		l.unlock()
+		conn.Send(&Message{Type: MessageError, Text: "lobby is full"})
+		conn.Close()
+		return nil
+	}
+	defer l.unlock()
+
+	l.nextID++
+	p := &lobbyPlayer{
+		Player:   Player{ID: l.nextID, Name: m.Name},
+		conn:     conn,
+		strategy: StrategyRandom,
+	}
+	l.players[p.ID] = p
+	if !l.playing && len(l.players) == l.minPlayers {
+		l.startAt = time.Now().Add(StartDelay)
+	}
+	l.send(conn, &Message{Type: MessageJoin, ID: p.ID})
+	log.Info("%s joined the lobby.", p.Name)
+	return p
+}
+
+func (l *Lobby) leave(p *lobbyPlayer) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	l.knockOut(p)
+	delete(l.players, p.ID)
+	p.conn.Close()
+	log.Info("%s left the lobby.", p.Name)
+}
+
+func (l *Lobby) serve(c net.Conn) {
+	conn, err := newConn(c)
+	if err != nil {
+		return
+	}
+	p := l.join(conn)
+	if p == nil {
+		return
+	}
+	defer l.leave(p)
+
+	for {
+		m, err := conn.Receive()
+		if err != nil || m.Type == MessageBye {
+			return
+		}
+
+		l.mu.Lock()
+		switch m.Type {
+		case MessageBoard:
+			p.Board = m.Board
+		case MessageGarbage:
+			l.attack(p, m.Lines)
+		case MessageOver:
+			l.knockOut(p)
+		case MessageTarget:
+			for _, s := range Strategies {
+				if m.Strategy == s {
+					p.strategy = s
+				}
+			}
+		}
+		l.unlock()
+	}
+}
+
+// Serve accepts players until the listener is closed.
+func (l *Lobby) Serve() error {
+	done := make(chan struct{})
+	defer close(done)
+	go l.run(done)
+
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return err
+		}
+		go l.serve(conn)
+	}
+}
+
+func (l *Lobby) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+func (l *Lobby) Close() error {
+	return l.listener.Close()
+}
+
+func NewLobby(addr string, minPlayers int) (*Lobby, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if minPlayers < MinPlayers {
+		minPlayers = MinPlayers
+	}
+	return &Lobby{
+		listener:   l,
+		players:    make(map[int]*lobbyPlayer),
+		minPlayers: minPlayers,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package netplay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lobbyBot is a player of a lobby topping out on the first garbage it
+// receives unless it's strong, in which case it attacks on every
+// roster until it's the last one standing.
+type lobbyBot struct {
+	name   string
+	strong bool
+	conn   *Conn
+}
+
+type lobbyResult struct {
+	name  string
+	place int
+	err   error
+}
+
+func (b *lobbyBot) play(results chan<- lobbyResult) {
+	for {
+		m, err := b.conn.Receive()
+		if err != nil {
+			results <- lobbyResult{b.name, 0, err}
+			return
+		}
+
+		switch m.Type {
+		case MessageStart:
+			b.conn.Send(&Message{Type: MessageGarbage, Lines: 1})
+		case MessagePlayers:
+			if b.strong {
+				b.conn.Send(&Message{Type: MessageGarbage, Lines: 2})
+			}
+		case MessageGarbage:
+			if !b.strong {
+				b.conn.Send(&Message{Type: MessageOver})
+			}
+		case MessageResult:
+			results <- lobbyResult{b.name, m.Place, nil}
+			return
+		}
+	}
+}
+
+func TestLobbyRound(t *testing.T) {
+	const players = 4
+	l, err := NewLobby("127.0.0.1:0", players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go l.Serve()
+
+	results := make(chan lobbyResult, players)
+	for i := 1; i <= players; i++ {
+		conn, err := Dial(l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		bot := &lobbyBot{name: fmt.Sprintf("bot%d", i), strong: i == 1, conn: conn}
+		conn.Send(&Message{Type: MessageJoin, Name: bot.name})
+		m, err := conn.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != MessageJoin || m.ID == 0 {
+			t.Fatalf("joined with %+v", m)
+		}
+		go bot.play(results)
+	}
+
+	// Start the round right away instead of after StartDelay.
+	l.mu.Lock()
+	l.startAt = time.Now()
+	l.mu.Unlock()
+
+	places := make(map[int]string)
+	timeout := time.After(10 * time.Second)
+	for i := 0; i < players; i++ {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				t.Fatalf("%s failed: %s", r.name, r.err)
+			}
+			if other, ok := places[r.place]; ok {
+				t.Fatalf("%s and %s took place %d", r.name, other, r.place)
+			}
+			places[r.place] = r.name
+		case <-timeout:
+			t.Fatalf("round didn't finish, places %v", places)
+		}
+	}
+
+	if places[1] != "bot1" {
+		t.Fatalf("%s won, expected bot1", places[1])
+	}
+	for place := 1; place <= players; place++ {
+		if places[place] == "" {
+			t.Fatalf("nobody took place %d, places %v", place, places)
+		}
+	}
+}
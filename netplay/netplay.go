@@ -0,0 +1,270 @@
+package netplay
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	ProtocolVersion = 2
+	DefaultAddr     = ":7777"
+
+	MessageHello   = "hello"
+	MessageBoard   = "board"
+	MessageDelta   = "delta"
+	MessageGarbage = "garbage"
+	MessageOver    = "over"
+	MessageRestart = "restart"
+	MessageBye     = "bye"
+	MessagePing    = "ping"
+
+	MessageJoin    = "join"
+	MessagePlayers = "players"
+	MessageStart   = "start"
+	MessageTarget  = "target"
+	MessageResult  = "result"
+	MessageError   = "error"
+
+	outgoingBuffer = 64
+
+	// WriteTimeout is how long a message may take to be written before
+	// the peer is considered gone.
+	WriteTimeout = 5 * time.Second
+
+	// Peers ping each other every KeepAliveInterval.
+	KeepAliveInterval = 2 * time.Second
+)
+
+// ReadTimeout is how long an opponent may stay silent on connections
+// made afterwards before it is considered gone.
+var ReadTimeout = 3 * KeepAliveInterval
+
+var (
+	VersionMismatchError = errors.New("opponent uses another protocol version")
+	HandshakeError       = errors.New("unexpected handshake message")
+	ClosedError          = errors.New("connection is closed")
+	StalledError         = errors.New("opponent doesn't keep up with messages")
+)
+
+// Block is a falling block. Cells are indexes of covered field cells.
+type Block struct {
+	Color uint16 `json:"color"`
+	Cells []int  `json:"cells"`
+}
+
+// Change is a new color of the field cell with the given index.
+type Change struct {
+	Index int    `json:"index"`
+	Color uint16 `json:"color"`
+}
+
+// Board is a snapshot of a board. Cells hold colors of the fixed cells
+// of the field row by row with zero for empty cells. Deltas carry
+// Changes of cells since the previous snapshot instead of Cells.
+type Board struct {
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	Cells      []uint16 `json:"cells,omitempty"`
+	Changes    []Change `json:"changes,omitempty"`
+	Blocks     []Block  `json:"blocks,omitempty"`
+	Level      string   `json:"level"`
+	Score      int      `json:"score"`
+	Lines      int      `json:"lines"`
+	BlockCount int      `json:"block_count"`
+	Elapsed    float64  `json:"elapsed"`
+	Pending    int      `json:"pending"`
+	Finished   bool     `json:"finished,omitempty"`
+}
+
+// Player is a player of a lobby as seen by the other players.
+type Player struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Alive  bool   `json:"alive"`
+	Badges int    `json:"badges"`
+	Place  int    `json:"place,omitempty"`
+	Target int    `json:"target,omitempty"`
+	Board  *Board `json:"board,omitempty"`
+}
+
+type Message struct {
+	Type     string    `json:"type"`
+	Version  int       `json:"version,omitempty"`
+	Board    *Board    `json:"board,omitempty"`
+	Lines    int       `json:"lines,omitempty"`
+	ID       int       `json:"id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Players  []*Player `json:"players,omitempty"`
+	Strategy string    `json:"strategy,omitempty"`
+	Place    int       `json:"place,omitempty"`
+	Text     string    `json:"text,omitempty"`
+}
+
+// Conn exchanges newline delimited JSON messages with the opponent.
+// Messages are written by a separate goroutine so sending and closing
+// never block the game loop on the network.
+type Conn struct {
+	conn net.Conn
+	dec  *json.Decoder
+	enc  *json.Encoder
+	out  chan *Message
+	done chan struct{}
+	once sync.Once
+	mu   sync.Mutex
+
+	readTimeout time.Duration
+}
+
+func (c *Conn) encode(m *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	return c.enc.Encode(m)
+}
+
+// write sends queued messages and pings until the connection is closed,
+// then says goodbye to the opponent and closes the socket.
+func (c *Conn) write() {
+	defer c.conn.Close()
+	ping := time.NewTicker(KeepAliveInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case m := <-c.out:
+			err := c.encode(m)
+			if err != nil {
+				c.Close()
+				return
+			}
+		case <-ping.C:
+			err := c.encode(&Message{Type: MessagePing})
+			if err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			c.encode(&Message{Type: MessageBye})
+			return
+		}
+	}
+}
+
+func (c *Conn) handshake() error {
+	err := c.encode(&Message{Type: MessageHello, Version: ProtocolVersion})
+	if err != nil {
+		return err
+	}
+
+	m, err := c.Receive()
+	if err != nil {
+		return err
+	}
+	if m.Type != MessageHello {
+		return HandshakeError
+	}
+	if m.Version != ProtocolVersion {
+		return VersionMismatchError
+	}
+	return nil
+}
+
+// TrySend queues m unless the outgoing buffer is full.
+func (c *Conn) TrySend(m *Message) bool {
+	select {
+	case c.out <- m:
+		return true
+	default:
+		return false
+	}
+}
+
+// Send queues m. An opponent not keeping up with the outgoing buffer
+// full is considered gone and the connection is closed.
+func (c *Conn) Send(m *Message) error {
+	select {
+	case <-c.done:
+		return ClosedError
+	default:
+	}
+	if !c.TrySend(m) {
+		c.Close()
+		return StalledError
+	}
+	return nil
+}
+
+// Receive returns the next message of the opponent, skipping pings. It
+// fails if the opponent stays silent for ReadTimeout.
+func (c *Conn) Receive() (*Message, error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		m := &Message{}
+		err := c.dec.Decode(m)
+		if err != nil {
+			return nil, err
+		}
+		if m.Type != MessagePing {
+			return m, nil
+		}
+	}
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes the connection without waiting for the network. The
+// writer goroutine says goodbye to the opponent and closes the socket,
+// at the latest after WriteTimeout if the opponent stalls.
+func (c *Conn) Close() error {
+	c.once.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
+func newConn(conn net.Conn) (*Conn, error) {
+	c := &Conn{
+		conn: conn,
+		dec:  json.NewDecoder(conn),
+		enc:  json.NewEncoder(conn),
+		out:  make(chan *Message, outgoingBuffer),
+		done: make(chan struct{}),
+
+		readTimeout: ReadTimeout,
+	}
+
+	err := c.handshake()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.write()
+	return c, nil
+}
+
+// Listen waits for a single opponent to join on addr.
+func Listen(addr string) (*Conn, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn)
+}
+
+func Dial(addr string) (*Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn)
+}
@@ -0,0 +1,155 @@
+package netplay
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a loopback address nothing listens on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// connect returns both ends of a connection made with Listen and Dial.
+func connect(t *testing.T) (*Conn, *Conn) {
+	addr := freeAddr(t)
+	type result struct {
+		conn *Conn
+		err  error
+	}
+	listened := make(chan result)
+	go func() {
+		conn, err := Listen(addr)
+		listened <- result{conn, err}
+	}()
+
+	var client *Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		client, err = Dial(addr)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-listened
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		r.conn.Close()
+	})
+	return r.conn, client
+}
+
+func receive(t *testing.T, c *Conn, typ string) *Message {
+	m, err := c.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != typ {
+		t.Fatalf("received %q, expected %q", m.Type, typ)
+	}
+	return m
+}
+
+func TestExchangeBoards(t *testing.T) {
+	host, guest := connect(t)
+
+	prev, next := testBoards()
+	err := host.Send(&Message{Type: MessageBoard, Board: prev})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, guest, MessageBoard)
+	if !reflect.DeepEqual(m.Board, prev) {
+		t.Fatalf("received %+v, expected %+v", m.Board, prev)
+	}
+
+	err = guest.Send(&Message{Type: MessageBoard, Board: next})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = receive(t, host, MessageBoard)
+	if !reflect.DeepEqual(m.Board, next) {
+		t.Fatalf("received %+v, expected %+v", m.Board, next)
+	}
+
+	guest.Close()
+	receive(t, host, MessageBye)
+}
+
+// rawPeer accepts a connection on a loopback listener and shakes hands
+// with it, then writes messages only when told to.
+func rawPeer(t *testing.T) (*Conn, *json.Encoder) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		json.NewEncoder(conn).Encode(&Message{Type: MessageHello, Version: ProtocolVersion})
+		accepted <- conn
+	}()
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, ok := <-accepted
+	if !ok {
+		t.Fatal("peer didn't connect")
+	}
+	t.Cleanup(func() {
+		c.Close()
+		conn.Close()
+	})
+	return c, json.NewEncoder(conn)
+}
+
+func TestReceiveSkipsPings(t *testing.T) {
+	c, peer := rawPeer(t)
+
+	for i := 0; i < 3; i++ {
+		peer.Encode(&Message{Type: MessagePing})
+	}
+	peer.Encode(&Message{Type: MessageGarbage, Lines: 2})
+	m := receive(t, c, MessageGarbage)
+	if m.Lines != 2 {
+		t.Fatalf("received %d lines, expected 2", m.Lines)
+	}
+}
+
+func TestSilentPeer(t *testing.T) {
+	c, peer := rawPeer(t)
+	c.readTimeout = 50 * time.Millisecond
+
+	peer.Encode(&Message{Type: MessagePing})
+	start := time.Now()
+	_, err := c.Receive()
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Fatalf("received %v from a silent peer, expected a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("silent peer dropped after %s", elapsed)
+	}
+}
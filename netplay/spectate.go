@@ -0,0 +1,137 @@
+package netplay
+
+import (
+	"net"
+	"sync"
+)
+
+// Diff returns a delta turning prev into next, or nil if next has to be
+// sent in full.
+func Diff(prev, next *Board) *Board {
+	if prev == nil || prev.Width != next.Width || prev.Height != next.Height {
+		return nil
+	}
+
+	delta := *next
+	delta.Cells = nil
+	for i, color := range next.Cells {
+		if prev.Cells[i] != color {
+			delta.Changes = append(delta.Changes, Change{Index: i, Color: color})
+		}
+	}
+	return &delta
+}
+
+// Apply returns the board updated with delta.
+func (b *Board) Apply(delta *Board) *Board {
+	next := *delta
+	next.Cells = make([]uint16, len(b.Cells))
+	next.Changes = nil
+	copy(next.Cells, b.Cells)
+	for _, c := range delta.Changes {
+		if c.Index >= 0 && c.Index < len(next.Cells) {
+			next.Cells[c.Index] = c.Color
+		}
+	}
+	return &next
+}
+
+// Broadcaster streams boards to read-only spectators. Spectators
+// joining mid-game receive the full board followed by deltas.
+type Broadcaster struct {
+	listener net.Listener
+	last     *Board
+	mu       sync.Mutex
+
+	// spectators tells whether spectators still need a full board,
+	// which is sent to them until one gets through.
+	spectators map[*Conn]bool
+}
+
+func (b *Broadcaster) accept() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			c, err := newConn(conn)
+			if err != nil {
+				return
+			}
+
+			b.mu.Lock()
+			b.spectators[c] = b.last == nil ||
+				!c.TrySend(&Message{Type: MessageBoard, Board: b.last})
+			b.mu.Unlock()
+
+			// Spectators only ping until they say goodbye or go
+			// silent.
+			for {
+				m, err := c.Receive()
+				if err != nil || m.Type == MessageBye {
+					break
+				}
+			}
+			b.mu.Lock()
+			delete(b.spectators, c)
+			b.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+// Publish sends board to every spectator. Spectators that can't keep
+// up are disconnected.
+func (b *Broadcaster) Publish(board *Board) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	full := &Message{Type: MessageBoard, Board: board}
+	m := full
+	if delta := Diff(b.last, board); delta != nil {
+		m = &Message{Type: MessageDelta, Board: delta}
+	}
+	b.last = board
+
+	for c, needsBoard := range b.spectators {
+		if needsBoard {
+			b.spectators[c] = !c.TrySend(full)
+			continue
+		}
+		if !c.TrySend(m) {
+			delete(b.spectators, c)
+			c.Close()
+		}
+	}
+}
+
+func (b *Broadcaster) Addr() net.Addr {
+	return b.listener.Addr()
+}
+
+func (b *Broadcaster) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.spectators {
+		delete(b.spectators, c)
+		c.Close()
+	}
+	return b.listener.Close()
+}
+
+func NewBroadcaster(addr string) (*Broadcaster, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Broadcaster{
+		listener:   l,
+		spectators: make(map[*Conn]bool),
+	}
+	go b.accept()
+	return b, nil
+}
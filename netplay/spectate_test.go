@@ -0,0 +1,79 @@
+package netplay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testBoards() (*Board, *Board) {
+	prev := &Board{
+		Width:  4,
+		Height: 2,
+		Cells:  []uint16{0, 0, 1, 2, 3, 3, 0, 7},
+		Blocks: []Block{{Color: 5, Cells: []int{0, 1}}},
+		Level:  "A",
+		Score:  130,
+		Lines:  1,
+	}
+	next := *prev
+	next.Cells = []uint16{0, 0, 0, 0, 3, 3, 0, 7}
+	next.Lines = 2
+	return prev, &next
+}
+
+func TestDiffApply(t *testing.T) {
+	prev, next := testBoards()
+	delta := Diff(prev, next)
+	if delta.Cells != nil || len(delta.Changes) != 2 {
+		t.Fatalf("delta %+v, expected two changes", delta)
+	}
+	if got := prev.Apply(delta); !reflect.DeepEqual(got, next) {
+		t.Fatalf("applied %+v, expected %+v", got, next)
+	}
+
+	resized := *next
+	resized.Width = 2
+	if Diff(prev, &resized) != nil {
+		t.Fatal("delta between boards of different sizes")
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b, err := NewBroadcaster("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	prev, next := testBoards()
+	b.Publish(prev)
+	c, err := Dial(b.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	m := receive(t, c, MessageBoard)
+	if !reflect.DeepEqual(m.Board, prev) {
+		t.Fatalf("received %+v, expected %+v", m.Board, prev)
+	}
+	b.Publish(next)
+	m = receive(t, c, MessageDelta)
+	if got := prev.Apply(m.Board); !reflect.DeepEqual(got, next) {
+		t.Fatalf("applied %+v, expected %+v", got, next)
+	}
+
+	// Spectators that missed their first board get full ones.
+	b.mu.Lock()
+	for spectator := range b.spectators {
+		b.spectators[spectator] = true
+	}
+	b.mu.Unlock()
+	b.Publish(prev)
+	m = receive(t, c, MessageBoard)
+	if !reflect.DeepEqual(m.Board, prev) {
+		t.Fatalf("received %+v, expected %+v", m.Board, prev)
+	}
+	b.Publish(next)
+	receive(t, c, MessageDelta)
+}
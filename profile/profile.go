@@ -0,0 +1,175 @@
+// Package profile keeps separate settings, high scores, history, saved
+// games and replays for everyone playing on a machine.
+//
+// The default profile lives right in the data directory and other
+// profiles in its profiles subdirectory.
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/CatWantsMeow/gtetris/xdg"
+)
+
+const (
+	DefaultName = "default"
+	ProfilesDir = "profiles"
+)
+
+var (
+	InvalidNameError = errors.New("profile names may only have letters, digits, - and _")
+	NotFoundError    = errors.New("no such profile")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+)
+
+// Settings are defaults of a profile used unless set by flags. Keys
+// replace key bindings of actions by name in the KeyPreset ones.
+type Settings struct {
+	Name       string              `json:"name"`
+	Mode       string              `json:"mode"`
+	Animations bool                `json:"animations"`
+	Mouse      bool                `json:"mouse,omitempty"`
+	KeyPreset  string              `json:"key_preset,omitempty"`
+	Keys       map[string][]string `json:"keys,omitempty"`
+	Ghost      bool                `json:"ghost"`
+	Previews   int                 `json:"previews,omitempty"`
+	DAS        int                 `json:"das,omitempty"`
+	ARR        int                 `json:"arr,omitempty"`
+	Theme      string              `json:"theme,omitempty"`
+	Glyphs     bool                `json:"glyphs,omitempty"`
+	Layout     string              `json:"layout,omitempty"`
+	Sound      bool                `json:"sound,omitempty"`
+}
+
+type Profile struct {
+	Name     string
+	Dir      string
+	Settings Settings
+}
+
+func (p *Profile) path(name string) string {
+	return filepath.Join(p.Dir, name)
+}
+
+func (p *Profile) SettingsPath() string {
+	return p.path("settings.json")
+}
+
+func (p *Profile) ScoresPath() string {
+	return p.path("scores.json")
+}
+
+func (p *Profile) HistoryPath() string {
+	return p.path("history.jsonl")
+}
+
+func (p *Profile) SavePath() string {
+	return p.path("save.json")
+}
+
+func (p *Profile) ReplayDir() string {
+	return p.path("replays")
+}
+
+func (p *Profile) Save() error {
+	data, err := json.MarshalIndent(&p.Settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(p.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.SettingsPath(), data, 0644)
+}
+
+func (p *Profile) load() error {
+	data, err := os.ReadFile(p.SettingsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &p.Settings)
+}
+
+func dir(name string) string {
+	if name == DefaultName {
+		return xdg.DataHome()
+	}
+	return filepath.Join(xdg.DataHome(), ProfilesDir, name)
+}
+
+func newProfile(name string) *Profile {
+	return &Profile{
+		Name: name,
+		Dir:  dir(name),
+		Settings: Settings{
+			Name:       name,
+			Mode:       "classic",
+			Animations: true,
+			Ghost:      true,
+			Previews:   1,
+		},
+	}
+}
+
+// List returns names of profiles, the default one first.
+func List() []string {
+	names := []string{DefaultName}
+	entries, _ := os.ReadDir(filepath.Join(xdg.DataHome(), ProfilesDir))
+	var others []string
+	for _, e := range entries {
+		if e.IsDir() && validName.MatchString(e.Name()) && e.Name() != DefaultName {
+			others = append(others, e.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// CheckName returns InvalidNameError if name can't be a profile name.
+func CheckName(name string) error {
+	if !validName.MatchString(name) {
+		return InvalidNameError
+	}
+	return nil
+}
+
+func Load(name string) (*Profile, error) {
+	err := CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	p := newProfile(name)
+	if name != DefaultName {
+		_, err := os.Stat(p.Dir)
+		if os.IsNotExist(err) {
+			return nil, NotFoundError
+		}
+	}
+	if name == DefaultName {
+		if user := os.Getenv("USER"); user != "" {
+			p.Settings.Name = user
+		}
+	}
+	return p, p.load()
+}
+
+// Create creates a profile with default settings, or loads it if it
+// already exists.
+func Create(name string) (*Profile, error) {
+	p, err := Load(name)
+	if err != NotFoundError {
+		return p, err
+	}
+	p = newProfile(name)
+	return p, p.Save()
+}
@@ -0,0 +1,302 @@
+// Package replay reads and writes recorded games. A replay is the seed
+// and rules of a game followed by inputs of the players with frames
+// they were applied after, which is enough for the deterministic engine
+// to play the game again.
+//
+// Files start with Magic and a version byte followed by varints:
+//
+//	seed, mode, flags, previews, width, height, start level,
+//	entry delay, line clear delay, name,
+//	final score, lines, blocks, frames, level,
+//	number of events, then for each event
+//	frames since the previous event and player<<4 | action.
+//
+// Strings are written as their length followed by bytes.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	Magic     = "GTR"
+	Version   = 1
+	Extension = ".gtr"
+
+	MaxStringLength = 256
+)
+
+const (
+	flagFast = 1 << iota
+	flagAnimations
+)
+
+// Actions of players. They are stored in replays, so new ones must only
+// be appended.
+const (
+	ActionLeft = iota
+	ActionRight
+	ActionRotate
+	ActionDown
+)
+
+var (
+	FormatError  = errors.New("not a replay file")
+	VersionError = errors.New("unsupported replay version")
+)
+
+type Event struct {
+	Frame  int
+	Player int
+	Action int
+}
+
+// Replay is a recorded game. Score, Lines, Blocks, Frames and Level are
+// the final stats which playback is verified against.
+type Replay struct {
+	Seed       int64
+	Mode       string
+	Fast       bool
+	Animations bool
+	Previews   int
+	Name       string
+
+	// Width, Height, StartLevel, EntryDelay and ClearDelay are zero for
+	// the default ones.
+	Width      int
+	Height     int
+	StartLevel int
+	EntryDelay int
+	ClearDelay int
+
+	Score  int
+	Lines  int
+	Blocks int
+	Frames int
+	Level  string
+
+	Events []Event
+}
+
+func (r *Replay) Record(frame, player, action int) {
+	r.Events = append(r.Events, Event{Frame: frame, Player: player, Action: action})
+}
+
+type writer struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) int(v int64) {
+	n := binary.PutVarint(w.buf[:], v)
+	w.w.Write(w.buf[:n])
+}
+
+func (w *writer) uint(v int) {
+	n := binary.PutUvarint(w.buf[:], uint64(v))
+	w.w.Write(w.buf[:n])
+}
+
+func (w *writer) string(s string) {
+	w.uint(len(s))
+	w.w.WriteString(s)
+}
+
+func (r *Replay) Encode(out io.Writer) error {
+	w := &writer{w: bufio.NewWriter(out)}
+	w.w.WriteString(Magic)
+	w.w.WriteByte(Version)
+
+	flags := 0
+	if r.Fast {
+		flags |= flagFast
+	}
+	if r.Animations {
+		flags |= flagAnimations
+	}
+	w.int(r.Seed)
+	w.string(r.Mode)
+	w.uint(flags)
+	w.uint(r.Previews)
+	w.uint(r.Width)
+	w.uint(r.Height)
+	w.uint(r.StartLevel)
+	w.int(int64(r.EntryDelay))
+	w.int(int64(r.ClearDelay))
+	w.string(r.Name)
+
+	w.uint(r.Score)
+	w.uint(r.Lines)
+	w.uint(r.Blocks)
+	w.uint(r.Frames)
+	w.string(r.Level)
+
+	w.uint(len(r.Events))
+	frame := 0
+	for _, e := range r.Events {
+		w.uint(e.Frame - frame)
+		w.uint(e.Player<<4 | e.Action)
+		frame = e.Frame
+	}
+	return w.w.Flush()
+}
+
+type reader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *reader) int() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(r.r)
+	r.err = err
+	return v
+}
+
+func (r *reader) uint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.r)
+	if err == nil && v > 1<<31 {
+		err = FormatError
+	}
+	r.err = err
+	return int(v)
+}
+
+func (r *reader) string() string {
+	n := r.uint()
+	if r.err != nil {
+		return ""
+	}
+	if n > MaxStringLength {
+		r.err = FormatError
+		return ""
+	}
+	buf := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, buf)
+	return string(buf)
+}
+
+func Decode(in io.Reader) (*Replay, error) {
+	r := &reader{r: bufio.NewReader(in)}
+	header := make([]byte, len(Magic)+1)
+	_, err := io.ReadFull(r.r, header)
+	if err != nil || string(header[:len(Magic)]) != Magic {
+		return nil, FormatError
+	}
+	if header[len(Magic)] != Version {
+		return nil, VersionError
+	}
+
+	rep := &Replay{}
+	rep.Seed = r.int()
+	rep.Mode = r.string()
+	flags := r.uint()
+	rep.Fast = flags&flagFast != 0
+	rep.Animations = flags&flagAnimations != 0
+	rep.Previews = r.uint()
+	rep.Width = r.uint()
+	rep.Height = r.uint()
+	rep.StartLevel = r.uint()
+	rep.EntryDelay = int(r.int())
+	rep.ClearDelay = int(r.int())
+	rep.Name = r.string()
+
+	rep.Score = r.uint()
+	rep.Lines = r.uint()
+	rep.Blocks = r.uint()
+	rep.Frames = r.uint()
+	rep.Level = r.string()
+
+	n := r.uint()
+	frame := 0
+	for i := 0; i < n && r.err == nil; i++ {
+		frame += r.uint()
+		input := r.uint()
+		rep.Record(frame, input>>4, input&0xf)
+	}
+
+	if r.err == io.EOF || r.err == io.ErrUnexpectedEOF {
+		return nil, FormatError
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return rep, nil
+}
+
+func Load(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+func (r *Replay) Save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = r.Encode(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SaveNew saves the replay to a new file in dir named by FileName after
+// its mode and t, numbering the name if replays of other games finished
+// in the same second took it, and returns the path of the file.
+func (r *Replay) SaveNew(dir string, t time.Time) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	for n := 1; ; n++ {
+		path := filepath.Join(dir, FileName(r.Mode, t, n))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		err = r.Encode(f)
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+// FileName names the nth replay of a game of mode finished at t, the
+// first one without a number.
+func FileName(mode string, t time.Time, n int) string {
+	name := fmt.Sprintf("%s-%s", mode, t.Format("20060102-150405"))
+	if n > 1 {
+		name += fmt.Sprintf("-%d", n)
+	}
+	return name + Extension
+}
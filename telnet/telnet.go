@@ -0,0 +1,246 @@
+// Package telnet hosts games for telnet clients, each connection
+// playing its own game on an ANSI terminal.
+//
+// The game doesn't need a server of its own to be played over ssh:
+// sshd already gives every session a terminal, so pointing ForceCommand
+// or the login shell of a dedicated user to gtetris is enough.
+package telnet
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/CatWantsMeow/gtetris/game"
+	"github.com/CatWantsMeow/gtetris/log"
+)
+
+const (
+	DefaultAddr = ":2323"
+
+	// TerminalTypeTimeout is how long sessions wait for clients to
+	// report their terminal type before showing basic colors.
+	TerminalTypeTimeout = time.Second
+
+	cmdSE   = 240
+	cmdSB   = 250
+	cmdWill = 251
+	cmdWont = 252
+	cmdDo   = 253
+	cmdDont = 254
+	cmdIAC  = 255
+
+	optEcho  = 1
+	optSGA   = 3
+	optTTYPE = 24
+	optNAWS  = 31
+
+	ttypeIs   = 0
+	ttypeSend = 1
+)
+
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+var (
+	// Ask the client for character mode without local echo, for
+	// reports of its window size and for its terminal type.
+	negotiation = []byte{
+		cmdIAC, cmdWill, optEcho,
+		cmdIAC, cmdWill, optSGA,
+		cmdIAC, cmdDo, optNAWS,
+		cmdIAC, cmdDo, optTTYPE,
+	}
+
+	ttypeRequest = []byte{cmdIAC, cmdSB, optTTYPE, ttypeSend, cmdIAC, cmdSE}
+)
+
+// session strips telnet commands from the input of a client and feeds
+// the rest to its terminal.
+type session struct {
+	conn  net.Conn
+	term  *game.ANSITerminal
+	state int
+	cmd   byte
+	sub   []byte
+
+	// ttype receives the first terminal type the client reports, empty
+	// if it refuses to.
+	ttype chan string
+}
+
+func (s *session) setTerminalType(name string) {
+	select {
+	case s.ttype <- name:
+	default:
+	}
+}
+
+// option answers the client agreeing to or refusing an option.
+func (s *session) option(opt byte) {
+	if opt != optTTYPE {
+		return
+	}
+	switch s.cmd {
+	case cmdWill:
+		s.conn.Write(ttypeRequest)
+	case cmdWont:
+		s.setTerminalType("")
+	}
+}
+
+func (s *session) subnegotiation() {
+	switch {
+	case len(s.sub) == 5 && s.sub[0] == optNAWS:
+		width := int(s.sub[1])<<8 | int(s.sub[2])
+		height := int(s.sub[3])<<8 | int(s.sub[4])
+		s.term.Resize(width, height)
+	case len(s.sub) >= 2 && s.sub[0] == optTTYPE && s.sub[1] == ttypeIs:
+		s.setTerminalType(string(s.sub[2:]))
+	}
+	s.sub = s.sub[:0]
+}
+
+// colorMode returns the name of the color mode of the terminal type
+// the client reports, basic colors if it doesn't in time.
+func (s *session) colorMode() string {
+	name := ""
+	select {
+	case name = <-s.ttype:
+	case <-time.After(TerminalTypeTimeout):
+	}
+	return game.TerminalColorMode(strings.ToLower(name), "").String()
+}
+
+func (s *session) parse(input []byte) []byte {
+	data := make([]byte, 0, len(input))
+	for _, b := range input {
+		switch s.state {
+		case stateData:
+			if b == cmdIAC {
+				s.state = stateIAC
+			} else {
+				data = append(data, b)
+			}
+		case stateIAC:
+			switch b {
+			case cmdIAC:
+				data = append(data, b)
+				s.state = stateData
+			case cmdWill, cmdWont, cmdDo, cmdDont:
+				s.cmd = b
+				s.state = stateOption
+			case cmdSB:
+				s.state = stateSub
+			default:
+				s.state = stateData
+			}
+		case stateOption:
+			s.option(b)
+			s.state = stateData
+		case stateSub:
+			if b == cmdIAC {
+				s.state = stateSubIAC
+			} else {
+				s.sub = append(s.sub, b)
+			}
+		case stateSubIAC:
+			switch b {
+			case cmdSE:
+				s.subnegotiation()
+				s.state = stateData
+			case cmdIAC:
+				s.sub = append(s.sub, b)
+				s.state = stateSub
+			default:
+				s.state = stateData
+			}
+		}
+	}
+	return data
+}
+
+func (s *session) read() {
+	defer s.term.CloseInput()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := s.conn.Read(buf)
+		if n > 0 {
+			s.term.Feed(s.parse(buf[:n]))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func serve(conn net.Conn, opts game.Options) {
+	defer conn.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Session of %s failed: %v.", conn.RemoteAddr(), r)
+		}
+	}()
+
+	log.Info("Client %s connected.", conn.RemoteAddr())
+	_, err := conn.Write(negotiation)
+	if err != nil {
+		return
+	}
+
+	s := &session{
+		conn:  conn,
+		term:  game.NewANSITerminal(conn),
+		ttype: make(chan string, 1),
+	}
+	go s.read()
+
+	// The colors of the host's terminal say nothing about the client's.
+	if opts.ColorMode == "" || opts.ColorMode == game.ColorsAuto {
+		opts.ColorMode = s.colorMode()
+	}
+
+	opts.Terminal = s.term
+	err = game.Run(opts)
+	if err != nil {
+		log.Error("Session of %s failed: %v.", conn.RemoteAddr(), err)
+	}
+	log.Info("Client %s disconnected.", conn.RemoteAddr())
+}
+
+// ListenAndServe hosts a game with opts for every client connecting to
+// addr. Sessions are anonymous and share the files of the host, so they
+// don't save games or replays, keep high scores and history or submit
+// scores to the host's leaderboard, and they detect colors from the
+// terminal types clients report rather than from the environment of the
+// host.
+func ListenAndServe(addr string, opts game.Options) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	opts.Debug = false
+	opts.Conn = nil
+	opts.Spectators = nil
+	opts.SaveFile = ""
+	opts.Resume = false
+	opts.HighScores = ""
+	opts.History = ""
+	opts.ReplayDir = ""
+	opts.Leaderboard = ""
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go serve(conn, opts)
+	}
+}
@@ -0,0 +1,33 @@
+// Package xdg locates per-user directories of the game following the
+// XDG base directory specification.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const AppName = "gtetris"
+
+func dir(env, fallback string) string {
+	if d := os.Getenv(env); filepath.IsAbs(d) {
+		return filepath.Join(d, AppName)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), AppName)
+	}
+	return filepath.Join(home, fallback, AppName)
+}
+
+// DataHome is the directory for replays, scores and other data kept
+// between games, ~/.local/share/gtetris by default.
+func DataHome() string {
+	return dir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// ConfigHome is the directory for configuration files, ~/.config/gtetris
+// by default.
+func ConfigHome() string {
+	return dir("XDG_CONFIG_HOME", ".config")
+}